@@ -27,6 +27,14 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	if len(os.Args) > 1 {
+		if err := app.Command(context.Background(), cfg, logger, os.Args[1:]); err != nil {
+			logger.Error("command failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	a, err := app.New(cfg, logger)
 	if err != nil {
 		logger.Error("failed to init app", "err", err)
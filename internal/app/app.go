@@ -2,13 +2,18 @@ package app
 
 import (
 	"context"
+	"crypto"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"warehouse/internal/auth"
 	"warehouse/internal/config"
 	httpx "warehouse/internal/http"
+	"warehouse/internal/migrate"
 	"warehouse/internal/repo"
+	"warehouse/internal/service"
 )
 
 type App struct {
@@ -17,18 +22,76 @@ type App struct {
 
 	db     *repo.DB
 	server *http.Server
+
+	// stop ends background jobs
+	stop context.CancelFunc
 }
 
 func New(cfg config.Config, logger *slog.Logger) (*App, error) {
+	jwtMgr, err := newJWTManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := repo.NewDB(cfg.DBDSN)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.AutoMigrate {
+		if err := migrateUp(db, logger); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := service.NewUsersService(db, repo.NewUsersRepo(db), repo.NewTokensRepo(db)).Bootstrap(ctx, cfg.AdminUsername, cfg.AdminPassword)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if created {
+		logger.Info("bootstrap admin created", "username", cfg.AdminUsername)
+	}
+
+	var signer crypto.Signer
+	if cfg.AuditSigningKey != "" {
+		if signer, err = auth.LoadPrivateKey(cfg.AuditSigningKey); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("audit signing key: %w", err)
+		}
+	}
+
+	bg, stop := context.WithCancel(context.Background())
+
+	events := service.NewEventsService(repo.NewHistoryRepo(db))
+	go events.Run(bg, logger)
+
+	go service.NewWebhooksService(repo.NewWebhooksRepo(db)).RunDispatcher(bg, logger)
+
+	tokens := service.NewTokensService(db, repo.NewTokensRepo(db), repo.NewUsersRepo(db), jwtMgr, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	go tokens.RunPurge(bg, time.Hour, logger)
+
+	lowStock := service.NewLowStockService(db, repo.NewReorderRepo(db), repo.NewItemsRepo(db), repo.NewLocationsRepo(db), newNotifiers(cfg, logger)...)
+	go lowStock.Run(bg, cfg.LowStockInterval, logger)
+
+	if cfg.ItemsPurgeAfter > 0 {
+		items := service.NewItemsService(db, repo.NewItemsRepo(db), repo.NewHistoryRepo(db), repo.NewMovementsRepo(db),
+			repo.NewStockRepo(db), repo.NewLocationsRepo(db), repo.NewLotsRepo(db), repo.NewSerialsRepo(db), repo.NewReservationsRepo(db), repo.NewWebhooksRepo(db), nil)
+		go items.RunPurge(bg, cfg.ItemsPurgeAfter, cfg.ItemsPurgeInterval, logger)
+	}
+
 	router := httpx.NewRouter(httpx.Deps{
-		Logger: logger,
-		DB:     db,
-		Cfg:    cfg,
+		Logger:      logger,
+		DB:          db,
+		JWT:         jwtMgr,
+		Cfg:         cfg,
+		AuditSigner: signer,
+		Events:      events,
+		LowStock:    lowStock,
 	})
 
 	srv := &http.Server{
@@ -40,19 +103,72 @@ func New(cfg config.Config, logger *slog.Logger) (*App, error) {
 		IdleTimeout:       60 * time.Second,
 	}
 
+	if signer != nil {
+		audit := service.NewAuditService(repo.NewAuditRepo(db), signer)
+		go audit.RunCheckpoints(bg, time.Hour, logger)
+	}
+
 	return &App{
 		cfg:    cfg,
 		logger: logger,
 		db:     db,
 		server: srv,
+		stop:   stop,
 	}, nil
 }
 
+// migrateUp waits as long as it takes for the lock: another instance may be
+// in the middle of a long migration.
+func migrateUp(db *repo.DB, logger *slog.Logger) error {
+	m, err := migrate.New(db.Pool)
+	if err != nil {
+		return err
+	}
+	done, err := m.Up(context.Background())
+	for _, mg := range done {
+		logger.Info("migration applied", "version", mg.Version, "name", mg.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+func newNotifiers(cfg config.Config, logger *slog.Logger) []service.Notifier {
+	var out []service.Notifier
+	for _, n := range cfg.LowStockNotifiers {
+		switch n {
+		case "log":
+			out = append(out, service.NewLogNotifier(logger))
+		case "webhook":
+			out = append(out, service.NewWebhookNotifier(cfg.LowStockWebhookURL, cfg.LowStockWebhookSecret))
+		case "smtp":
+			out = append(out, service.NewSMTPNotifier(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPTo, cfg.SMTPUsername, cfg.SMTPPassword))
+		}
+	}
+	return out
+}
+
+func newJWTManager(cfg config.Config) (*auth.Manager, error) {
+	if cfg.JWTKeysDir == "" {
+		return auth.NewManager(cfg.JWTSecret), nil
+	}
+
+	keys, err := auth.LoadKeys(cfg.JWTKeysDir)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewKeySetManager(keys, cfg.AccessTokenTTL)
+}
+
 func (a *App) Run() error {
 	return a.server.ListenAndServe()
 }
 
 func (a *App) Shutdown(ctx context.Context) error {
+	if a.stop != nil {
+		a.stop()
+	}
 	if a.db != nil {
 		a.db.Close()
 	}
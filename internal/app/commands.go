@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"warehouse/internal/auth"
+	"warehouse/internal/config"
+	"warehouse/internal/migrate"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+var ErrChainBroken = errors.New("history chain is broken")
+
+// Command runs a one-off maintenance command instead of the server:
+//
+//	migrate up                apply pending schema migrations
+//	migrate down [N]          revert the last N migrations (default 1)
+//	migrate status            list migrations and when they were applied
+//	audit verify              walk the history hash chain, exit 1 if broken
+//	audit checkpoint [DATE]   sign the chain head for DATE (default yesterday)
+func Command(ctx context.Context, cfg config.Config, logger *slog.Logger, args []string) error {
+	if len(args) < 2 || (args[0] != "audit" && args[0] != "migrate") {
+		return fmt.Errorf("unknown command %q", args)
+	}
+
+	db, err := repo.NewDB(cfg.DBDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if args[0] == "migrate" {
+		return migrateCommand(ctx, db, logger, args[1:])
+	}
+
+	var signer crypto.Signer
+	if cfg.AuditSigningKey != "" {
+		if signer, err = auth.LoadPrivateKey(cfg.AuditSigningKey); err != nil {
+			return fmt.Errorf("audit signing key: %w", err)
+		}
+	}
+	audit := service.NewAuditService(repo.NewAuditRepo(db), signer)
+
+	switch args[1] {
+	case "verify":
+		rep, err := audit.Verify(ctx)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+		if !rep.OK {
+			return ErrChainBroken
+		}
+		return nil
+
+	case "checkpoint":
+		day := time.Now().UTC().AddDate(0, 0, -1)
+		if len(args) > 2 {
+			if day, err = time.Parse(time.DateOnly, args[2]); err != nil {
+				return errors.New("date must be YYYY-MM-DD")
+			}
+		}
+		created, err := audit.Checkpoint(ctx, day)
+		if err != nil {
+			return err
+		}
+		logger.Info("history checkpoint", "day", day.Format(time.DateOnly), "created", created)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown audit command %q", args[1])
+	}
+}
+
+func migrateCommand(ctx context.Context, db *repo.DB, logger *slog.Logger, args []string) error {
+	m, err := migrate.New(db.Pool)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mg := range done {
+			logger.Info("migration applied", "version", mg.Version, "name", mg.Name)
+		}
+		if err == nil && len(done) == 0 {
+			logger.Info("schema is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return errors.New("number of steps must be a positive integer")
+			}
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mg := range done {
+			logger.Info("migration reverted", "version", mg.Version, "name", mg.Name)
+		}
+		return err
+
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, st := range list {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format(time.RFC3339)
+			}
+			name := st.Name
+			if st.Unknown {
+				name = "(unknown to this binary)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, name, applied)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
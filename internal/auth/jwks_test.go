@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testKey(t *testing.T, id string, notBefore, notAfter time.Time) *Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodEdDSA, Private: priv, NotBefore: notBefore, NotAfter: notAfter}
+}
+
+// signWith signs a token with k whether or not k may sign right now.
+func signWith(t *testing.T, k *Key) string {
+	t.Helper()
+	now := time.Now()
+	tok := jwt.NewWithClaims(k.Method, &Claims{
+		Username: "u",
+		Role:     "viewer",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "u",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	tok.Header["kid"] = k.ID
+	s, err := tok.SignedString(k.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWKSAndVerifyAcrossRotation(t *testing.T) {
+	now := time.Now()
+	grace := time.Hour
+	past := testKey(t, "past", now.Add(-48*time.Hour), now.Add(-2*time.Hour))
+	active := testKey(t, "active", now.Add(-2*time.Hour), now.Add(24*time.Hour))
+	future := testKey(t, "future", now.Add(24*time.Hour), now.Add(48*time.Hour))
+
+	m, err := NewKeySetManager([]*Key{past, active, future}, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := map[string]bool{}
+	for _, k := range m.JWKS().Keys {
+		published[k.Kid] = true
+	}
+	want := map[string]bool{"past": false, "active": true, "future": true}
+	for kid, ok := range want {
+		if published[kid] != ok {
+			t.Errorf("key %s published: got %v, want %v", kid, published[kid], ok)
+		}
+	}
+
+	signed, _, err := m.Generate("u", "viewer", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(signed); err != nil {
+		t.Fatalf("token from the active key: %v", err)
+	}
+	if _, err := m.Parse(signWith(t, past)); err == nil {
+		t.Fatal("token from a key past its grace period verified")
+	}
+	if _, err := m.Parse(signWith(t, future)); err == nil {
+		t.Fatal("token from a key that is not valid yet verified")
+	}
+}
@@ -7,29 +7,66 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var ErrNoSigningKey = errors.New("no active signing key")
+
 type Manager struct {
 	secret []byte
+
+	keys  []*Key
+	grace time.Duration
 }
 
+// NewManager signs and verifies with a shared HS256 secret. Meant for dev.
 func NewManager(secret string) *Manager {
 	return &Manager{secret: []byte(secret)}
 }
 
-func (m *Manager) Generate(username, role string, ttl time.Duration) (string, error) {
+// NewKeySetManager signs with the newest active key of the set and verifies
+// with any key that signed within the last grace period (the token TTL).
+// It fails unless some key of the set can sign right now.
+func NewKeySetManager(keys []*Key, grace time.Duration) (*Manager, error) {
+	m := &Manager{keys: keys, grace: grace}
+	if m.signingKey(time.Now()) == nil {
+		return nil, ErrNoSigningKey
+	}
+	return m, nil
+}
+
+func (m *Manager) Generate(username, role string, ttl time.Duration) (string, *Claims, error) {
 	now := time.Now()
 
-	claims := Claims{
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &Claims{
 		Username: username,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			Subject:   username,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString(m.secret)
+	var signed string
+	if m.keys == nil {
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	} else {
+		k := m.signingKey(now)
+		if k == nil {
+			return "", nil, ErrNoSigningKey
+		}
+		t := jwt.NewWithClaims(k.Method, claims)
+		t.Header["kid"] = k.ID
+		signed, err = t.SignedString(k.Private)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
 func (m *Manager) Parse(tokenString string) (*Claims, error) {
@@ -37,12 +74,7 @@ func (m *Manager) Parse(tokenString string) (*Claims, error) {
 		return nil, errors.New("empty token")
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		if token.Method != jwt.SigningMethodHS256 {
-			return nil, errors.New("unexpected signing method")
-		}
-		return m.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.keyFunc)
 	if err != nil {
 		return nil, err
 	}
@@ -54,3 +86,44 @@ func (m *Manager) Parse(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+func (m *Manager) keyFunc(token *jwt.Token) (any, error) {
+	if m.keys == nil {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	for _, k := range m.keys {
+		if k.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		now := time.Now()
+		if now.Before(k.NotBefore) {
+			return nil, errors.New("signing key not yet valid")
+		}
+		if !k.canVerify(now, m.grace) {
+			return nil, errors.New("signing key expired")
+		}
+		return k.Public(), nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+func (m *Manager) signingKey(now time.Time) *Key {
+	var best *Key
+	for _, k := range m.keys {
+		if !k.canSign(now) {
+			continue
+		}
+		if best == nil || k.NotBefore.After(best.NotBefore) {
+			best = k
+		}
+	}
+	return best
+}
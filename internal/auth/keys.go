@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one asymmetric signing key of the key set. A key signs tokens
+// between NotBefore and NotAfter and keeps verifying them for the token
+// lifetime after NotAfter, so rotating keys never invalidates live sessions.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+func (k *Key) canSign(now time.Time) bool {
+	if now.Before(k.NotBefore) {
+		return false
+	}
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
+
+func (k *Key) canVerify(now time.Time, grace time.Duration) bool {
+	if now.Before(k.NotBefore) {
+		return false
+	}
+	return k.canPublish(now, grace)
+}
+
+// canPublish ignores NotBefore: scheduled keys go out in the JWKS before they
+// sign anything.
+func (k *Key) canPublish(now time.Time, grace time.Duration) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter.Add(grace))
+}
+
+type manifestEntry struct {
+	KID       string    `json:"kid"`
+	File      string    `json:"file"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// LoadKeys reads dir/keys.json and the PEM encoded private keys it lists.
+// RSA keys sign with RS256, Ed25519 keys with EdDSA.
+func LoadKeys(dir string) ([]*Key, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, "keys.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []manifestEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("keys.json: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("keys.json: no keys")
+	}
+
+	keys := make([]*Key, 0, len(entries))
+	seen := map[string]bool{}
+	for _, e := range entries {
+		e.KID = strings.TrimSpace(e.KID)
+		if e.KID == "" || e.File == "" {
+			return nil, errors.New("keys.json: kid and file are required")
+		}
+		if seen[e.KID] {
+			return nil, fmt.Errorf("keys.json: duplicate kid %q", e.KID)
+		}
+		seen[e.KID] = true
+
+		if !e.NotAfter.IsZero() && !e.NotAfter.After(e.NotBefore) {
+			return nil, fmt.Errorf("key %s: not_after must be after not_before", e.KID)
+		}
+
+		signer, method, err := readPrivateKey(filepath.Join(dir, e.File))
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", e.KID, err)
+		}
+
+		keys = append(keys, &Key{
+			ID:        e.KID,
+			Method:    method,
+			Private:   signer,
+			NotBefore: e.NotBefore,
+			NotAfter:  e.NotAfter,
+		})
+	}
+	return keys, nil
+}
+
+// LoadPrivateKey reads a single PEM encoded RSA or Ed25519 private key.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	signer, _, err := readPrivateKey(path)
+	return signer, err
+}
+
+func readPrivateKey(path string) (crypto.Signer, jwt.SigningMethod, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM type %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		return k, jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey:
+		return k, jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
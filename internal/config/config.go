@@ -2,7 +2,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -10,6 +14,42 @@ type Config struct {
 	DBDSN     string
 	JWTSecret string
 	Env       string
+
+	// JWTKeysDir switches token signing from the HS256 JWTSecret to the
+	// asymmetric key set described by JWTKeysDir/keys.json.
+	JWTKeysDir string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	AdminUsername string
+	AdminPassword string
+
+	// AuditSigningKey is a PEM private key; when set, signed daily
+	// checkpoints of the history hash chain are written.
+	AuditSigningKey string
+
+	// AutoMigrate applies pending schema migrations on startup.
+	AutoMigrate bool
+
+	// ItemsPurgeAfter is how long deleted items stay in the trash before
+	// they are removed for good, 0 keeps them forever. The trash is checked
+	// every ItemsPurgeInterval.
+	ItemsPurgeAfter    time.Duration
+	ItemsPurgeInterval time.Duration
+
+	// LowStockNotifiers lists where low-stock alerts go (log, webhook,
+	// smtp); all reorder points are re-checked every LowStockInterval.
+	LowStockNotifiers     []string
+	LowStockInterval      time.Duration
+	LowStockWebhookURL    string
+	LowStockWebhookSecret string
+
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPTo       []string
+	SMTPUsername string
+	SMTPPassword string
 }
 
 func Load() (Config, error) {
@@ -18,10 +58,64 @@ func Load() (Config, error) {
 		DBDSN:     getEnv("DB_DSN", ""),
 		JWTSecret: getEnv("JWT_SECRET", ""),
 		Env:       getEnv("ENV", "dev"),
+
+		JWTKeysDir: getEnv("JWT_KEYS_DIR", ""),
+
+		AdminUsername: getEnv("ADMIN_USERNAME", ""),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
+		AuditSigningKey: getEnv("AUDIT_SIGNING_KEY", ""),
+
+		LowStockNotifiers:     getList("LOW_STOCK_NOTIFIERS", "log"),
+		LowStockWebhookURL:    getEnv("LOW_STOCK_WEBHOOK_URL", ""),
+		LowStockWebhookSecret: getEnv("LOW_STOCK_WEBHOOK_SECRET", ""),
+
+		SMTPAddr:     getEnv("SMTP_ADDR", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+		SMTPTo:       getList("SMTP_TO", ""),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+	}
+
+	var err error
+	if cfg.AutoMigrate, err = getBool("DB_AUTO_MIGRATE", true); err != nil {
+		return Config{}, err
+	}
+	if cfg.AccessTokenTTL, err = getDuration("ACCESS_TOKEN_TTL", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.RefreshTokenTTL, err = getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if getEnv("ITEMS_PURGE_AFTER", "") != "0" {
+		if cfg.ItemsPurgeAfter, err = getDuration("ITEMS_PURGE_AFTER", 30*24*time.Hour); err != nil {
+			return Config{}, err
+		}
+	}
+	if cfg.ItemsPurgeInterval, err = getDuration("ITEMS_PURGE_INTERVAL", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.LowStockInterval, err = getDuration("LOW_STOCK_INTERVAL", 5*time.Minute); err != nil {
+		return Config{}, err
+	}
+	for _, n := range cfg.LowStockNotifiers {
+		switch n {
+		case "log":
+		case "webhook":
+			if cfg.LowStockWebhookURL == "" {
+				return Config{}, errors.New("LOW_STOCK_WEBHOOK_URL is required for the webhook notifier")
+			}
+		case "smtp":
+			if cfg.SMTPAddr == "" || cfg.SMTPFrom == "" || len(cfg.SMTPTo) == 0 {
+				return Config{}, errors.New("SMTP_ADDR, SMTP_FROM and SMTP_TO are required for the smtp notifier")
+			}
+		default:
+			return Config{}, fmt.Errorf("LOW_STOCK_NOTIFIERS: unknown notifier %q", n)
+		}
 	}
 
-	if cfg.JWTSecret == "" {
-		return Config{}, errors.New("JWT_SECRET is required")
+	if cfg.JWTSecret == "" && cfg.JWTKeysDir == "" {
+		return Config{}, errors.New("JWT_SECRET or JWT_KEYS_DIR is required")
 	}
 	if cfg.DBDSN == "" {
 		return Config{}, errors.New("DB_DSN is required")
@@ -36,3 +130,38 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+// getList splits a comma separated value, dropping empty entries.
+func getList(key, def string) []string {
+	var out []string
+	for _, v := range strings.Split(getEnv(key, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration", key)
+	}
+	return d, nil
+}
+
+func getBool(key string, def bool) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return b, nil
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// ChainLink is one row of the items_history hash chain as stored, together
+// with the hash the database computes for its current contents.
+type ChainLink struct {
+	Seq      *int64
+	ID       int64
+	PrevHash []byte
+	Hash     []byte
+	Expected []byte
+}
+
+type ChainBreak struct {
+	Seq       *int64 `json:"seq,omitempty"`
+	HistoryID int64  `json:"history_id,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// Signatures in a ChainReport: checkpoint signatures were checked against
+// the signing key, or no key is configured and only their hashes were.
+const (
+	SignaturesVerified = "verified"
+	SignaturesUnsigned = "unsigned"
+)
+
+type ChainReport struct {
+	OK                 bool        `json:"ok"`
+	Checked            int64       `json:"checked"`
+	HeadSeq            int64       `json:"head_seq"`
+	HeadHash           string      `json:"head_hash,omitempty"`
+	CheckpointsChecked int         `json:"checkpoints_checked"`
+	Signatures         string      `json:"signatures"`
+	FirstBroken        *ChainBreak `json:"first_broken,omitempty"`
+}
+
+// Checkpoint pins the head of the chain at a point in time. Message is what
+// was signed, so a checkpoint can be verified offline with the public key.
+type Checkpoint struct {
+	Day       string    `json:"day"`
+	LastSeq   int64     `json:"last_seq"`
+	LastHash  string    `json:"last_hash"`
+	Message   string    `json:"message"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
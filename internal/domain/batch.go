@@ -0,0 +1,32 @@
+package domain
+
+type BatchOpKind string
+
+const (
+	BatchCreate BatchOpKind = "create"
+	BatchUpdate BatchOpKind = "update"
+	BatchPatch  BatchOpKind = "patch"
+	BatchDelete BatchOpKind = "delete"
+)
+
+// BatchOp is one operation of a batch. Create uses Create; update and patch
+// compute the new state of the locked item with Apply.
+type BatchOp struct {
+	Kind    BatchOpKind
+	ID      int64
+	Version *int64
+	Create  ItemCreate
+	Apply   func(cur Item) (ItemUpdate, error)
+}
+
+type BatchOpResult struct {
+	Index int         `json:"index"`
+	Op    BatchOpKind `json:"op"`
+	ID    int64       `json:"id"`
+	Item  *Item       `json:"item,omitempty"`
+}
+
+type BatchResult struct {
+	BatchID string          `json:"batch_id"`
+	Results []BatchOpResult `json:"results"`
+}
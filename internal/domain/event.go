@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// Item change event types of the live feed.
+const (
+	EventItemCreated  = "item.created"
+	EventItemUpdated  = "item.updated"
+	EventItemDeleted  = "item.deleted"
+	EventItemReverted = "item.reverted"
+	EventItemRestored = "item.restored"
+	EventItemPurged   = "item.purged"
+
+	// a lot of the item was created or its stock changed
+	EventItemLotChanged = "item.lot_changed"
+
+	// a serial of the item was registered or changed status or location
+	EventItemSerialChanged = "item.serial_changed"
+
+	// webhooks only: qty differs between before and after
+	EventItemQtyChanged = "item.qty_changed"
+)
+
+// ItemEvent is one items_history entry as pushed to feed subscribers. ID is
+// the history id. Seq is the SSE event id used for resuming: history ids are
+// taken before commit and can commit out of order, seq follows commit order.
+type ItemEvent struct {
+	ID        int64     `json:"id"`
+	Seq       int64     `json:"seq"`
+	Type      string    `json:"type"`
+	ItemID    int64     `json:"item_id"`
+	Version   *int64    `json:"version,omitempty"`
+	Actor     *string   `json:"actor,omitempty"`
+	ActorRole *string   `json:"actor_role,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+	Item      any       `json:"item,omitempty"`
+	Lot       any       `json:"lot,omitempty"`
+	Serial    any       `json:"serial,omitempty"`
+	Changes   any       `json:"changes,omitempty"`
+}
+
+type EventFilter struct {
+	ItemID   *int64
+	Location *string
+}
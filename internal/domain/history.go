@@ -3,20 +3,46 @@ package domain
 import "time"
 
 type HistoryEntry struct {
-	ID        int64     `json:"id"`
-	ItemID    int64     `json:"item_id"`
-	Action    string    `json:"action"`
-	Actor     *string   `json:"actor,omitempty"`
-	ActorRole *string   `json:"actor_role,omitempty"`
-	ChangedAt time.Time `json:"changed_at"`
-	OldData   any       `json:"old_data,omitempty"`
-	NewData   any       `json:"new_data,omitempty"`
-	Changes   any       `json:"changes,omitempty"`
+	ID            int64     `json:"id"`
+	Seq           *int64    `json:"seq,omitempty"`
+	ItemID        int64     `json:"item_id"`
+	Action        string    `json:"action"`
+	Version       *int64    `json:"version,omitempty"`
+	Actor         *string   `json:"actor,omitempty"`
+	ActorRole     *string   `json:"actor_role,omitempty"`
+	CorrelationID *string   `json:"correlation_id,omitempty"`
+	SourceID      *int64    `json:"source_history_id,omitempty"`
+	BatchID       *string   `json:"batch_id,omitempty"`
+	ChangedAt     time.Time `json:"changed_at"`
+	OldData       any       `json:"old_data,omitempty"`
+	NewData       any       `json:"new_data,omitempty"`
+	Changes       any       `json:"changes,omitempty"`
 }
 
 type HistoryFilter struct {
-	From   *time.Time
-	To     *time.Time
-	User   *string
-	Action *string
+	ItemID  *int64
+	SKU     *string
+	From    *time.Time
+	To      *time.Time
+	User    *string
+	Action  *string
+	BatchID *string
+}
+
+type HistoryCursor struct {
+	ChangedAt time.Time `json:"t"`
+	ID        int64     `json:"id"`
+}
+
+type HistoryPage struct {
+	Entries []HistoryEntry
+	Next    *HistoryCursor
+}
+
+// InventorySnapshot is the state of the whole inventory rebuilt from history.
+type InventorySnapshot struct {
+	AsOf      time.Time `json:"as_of"`
+	Items     []Item    `json:"items"`
+	ItemCount int       `json:"item_count"`
+	TotalQty  int64     `json:"total_qty"`
 }
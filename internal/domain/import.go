@@ -0,0 +1,52 @@
+package domain
+
+// ImportMode decides what happens to the valid rows of an import that also
+// has invalid ones.
+type ImportMode string
+
+const (
+	// ImportAtomic writes nothing unless every row is valid and succeeds.
+	ImportAtomic ImportMode = "atomic"
+	// ImportBestEffort writes the rows that succeed and reports the rest.
+	ImportBestEffort ImportMode = "best_effort"
+)
+
+// ImportRow is one data row of an import file with the mapped cells as
+// text; Line is its line in the file for error reports.
+type ImportRow struct {
+	Line     int
+	SKU      string
+	Name     string
+	Qty      string
+	Location string
+}
+
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+	ImportError     ImportAction = "error"
+)
+
+type ImportRowResult struct {
+	Line   int          `json:"line"`
+	SKU    string       `json:"sku"`
+	Action ImportAction `json:"action"`
+	ItemID *int64       `json:"item_id,omitempty"`
+	Errors []string     `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun        bool              `json:"dry_run"`
+	Mode          ImportMode        `json:"mode"`
+	Committed     bool              `json:"committed"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Total         int               `json:"total"`
+	Created       int               `json:"created"`
+	Updated       int               `json:"updated"`
+	Unchanged     int               `json:"unchanged"`
+	Failed        int               `json:"failed"`
+	Rows          []ImportRowResult `json:"rows"`
+}
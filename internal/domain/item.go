@@ -2,14 +2,35 @@ package domain
 
 import "time"
 
+// Tracking is how the stock of an item is identified beyond its qty.
+type Tracking string
+
+const (
+	TrackingNone   Tracking = "none"
+	TrackingLot    Tracking = "lot"
+	TrackingSerial Tracking = "serial"
+)
+
 type Item struct {
 	ID       int64     `json:"id"`
 	SKU      string    `json:"sku"`
 	Name     string    `json:"name"`
 	Qty      int       `json:"qty"`
 	Location *string   `json:"location,omitempty"`
+	Version  int64     `json:"version"`
+	Tracking Tracking  `json:"tracking"`
 	Created  time.Time `json:"created_at"`
 	Updated  time.Time `json:"updated_at"`
+
+	// on_hand is qty; available is what is left after live reservations
+	// and may go negative when stock was issued around them
+	OnHand    int `json:"on_hand"`
+	Reserved  int `json:"reserved"`
+	Available int `json:"available"`
+
+	// set while the item is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *string    `json:"deleted_by,omitempty"`
 }
 
 type ItemCreate struct {
@@ -17,6 +38,7 @@ type ItemCreate struct {
 	Name     string
 	Qty      int
 	Location *string
+	Tracking Tracking
 }
 
 type ItemUpdate struct {
@@ -25,3 +47,43 @@ type ItemUpdate struct {
 	Qty      int
 	Location *string
 }
+
+type ItemSort string
+
+const (
+	ItemSortID        ItemSort = "id"
+	ItemSortSKU       ItemSort = "sku"
+	ItemSortName      ItemSort = "name"
+	ItemSortQty       ItemSort = "qty"
+	ItemSortUpdatedAt ItemSort = "updated_at"
+)
+
+// ItemCursor points at the last row of a page: its sort value (as text)
+// and id as the tie breaker.
+type ItemCursor struct {
+	Sort  ItemSort `json:"s"`
+	Desc  bool     `json:"d"`
+	Value string   `json:"v"`
+	ID    int64    `json:"id"`
+}
+
+type ItemQuery struct {
+	Search       string
+	QtyLT        *int
+	Location     *string
+	UpdatedSince *time.Time
+
+	// IncludeDeleted lists items in the trash along with the others
+	IncludeDeleted bool
+
+	Sort  ItemSort
+	Desc  bool
+	Limit int
+	After *ItemCursor
+}
+
+type ItemPage struct {
+	Items []Item
+	Next  *ItemCursor
+	Total int64
+}
@@ -0,0 +1,68 @@
+package domain
+
+import "time"
+
+type LocationKind string
+
+const (
+	LocationWarehouse LocationKind = "warehouse"
+	LocationZone      LocationKind = "zone"
+	LocationAisle     LocationKind = "aisle"
+	LocationBin       LocationKind = "bin"
+)
+
+// Depth returns the level of the kind in the warehouse → zone → aisle → bin
+// hierarchy, or -1 for an unknown kind.
+func (k LocationKind) Depth() int {
+	switch k {
+	case LocationWarehouse:
+		return 0
+	case LocationZone:
+		return 1
+	case LocationAisle:
+		return 2
+	case LocationBin:
+		return 3
+	default:
+		return -1
+	}
+}
+
+type Location struct {
+	ID       int64        `json:"id"`
+	ParentID *int64       `json:"parent_id,omitempty"`
+	Kind     LocationKind `json:"kind"`
+	Code     string       `json:"code"`
+	Name     *string      `json:"name,omitempty"`
+	Created  time.Time    `json:"created_at"`
+}
+
+type LocationCreate struct {
+	ParentID *int64
+	Kind     LocationKind
+	Code     string
+	Name     *string
+}
+
+// StockLevel is the quantity of one item at one location. LocationID is nil
+// for stock that has not been put away to any location yet.
+type StockLevel struct {
+	ItemID       int64   `json:"item_id"`
+	SKU          string  `json:"sku,omitempty"`
+	Name         string  `json:"name,omitempty"`
+	LocationID   *int64  `json:"location_id"`
+	LocationCode *string `json:"location_code"`
+	Qty          int     `json:"qty"`
+}
+
+type ItemStock struct {
+	ItemID int64        `json:"item_id"`
+	Total  int          `json:"total"`
+	Levels []StockLevel `json:"levels"`
+}
+
+type LocationStock struct {
+	Location Location     `json:"location"`
+	Total    int          `json:"total"`
+	Levels   []StockLevel `json:"levels"`
+}
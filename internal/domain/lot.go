@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Lot is a part of an item's stock received under one lot number. Dates are
+// calendar days (midnight UTC).
+type Lot struct {
+	ID             int64      `json:"id"`
+	ItemID         int64      `json:"item_id"`
+	Number         string     `json:"lot_number"`
+	ManufacturedOn *time.Time `json:"manufactured_on,omitempty"`
+	ExpiresOn      *time.Time `json:"expires_on,omitempty"`
+	Supplier       *string    `json:"supplier,omitempty"`
+	Qty            int        `json:"qty"`
+	Created        time.Time  `json:"created_at"`
+	Updated        time.Time  `json:"updated_at"`
+}
+
+// LotInput names the lot a receive books into. The dates and supplier are
+// stored when the lot is created and must match when it already exists.
+type LotInput struct {
+	Number         string
+	ManufacturedOn *time.Time
+	ExpiresOn      *time.Time
+	Supplier       *string
+}
+
+// ExpiringLot is a line of the expiry report.
+type ExpiringLot struct {
+	Lot
+	SKU      string `json:"sku"`
+	Name     string `json:"name"`
+	DaysLeft int    `json:"days_left"`
+}
@@ -0,0 +1,65 @@
+package domain
+
+import "time"
+
+type MovementKind string
+
+const (
+	MovementReceive  MovementKind = "receive"
+	MovementIssue    MovementKind = "issue"
+	MovementAdjust   MovementKind = "adjust"
+	MovementTransfer MovementKind = "transfer"
+)
+
+// Reason codes written by the service itself rather than by a client.
+const (
+	ReasonInitial    = "initial"
+	ReasonManualEdit = "manual_edit"
+	ReasonRevert     = "revert"
+	ReasonImport     = "import"
+	ReasonBatch      = "batch"
+)
+
+type Movement struct {
+	ID            int64        `json:"id"`
+	ItemID        int64        `json:"item_id"`
+	Kind          MovementKind `json:"kind"`
+	QtyDelta      int          `json:"qty_delta"`
+	QtyAfter      int          `json:"qty_after"`
+	Location      *string      `json:"location,omitempty"`
+	LotID         *int64       `json:"lot_id,omitempty"`
+	LotNumber     *string      `json:"lot_number,omitempty"`
+	Reason        *string      `json:"reason,omitempty"`
+	Reference     *string      `json:"reference,omitempty"`
+	CorrelationID *string      `json:"correlation_id,omitempty"`
+	Actor         *string      `json:"actor,omitempty"`
+	ActorRole     *string      `json:"actor_role,omitempty"`
+	Created       time.Time    `json:"created_at"`
+}
+
+// MovementRequest is a stock operation on one item. Qty is the amount for
+// receive, issue and transfer and the signed delta for adjust. Location is
+// where the stock is booked (the source for transfers) and defaults to the
+// item's primary location.
+//
+// Serials names the units of a serialized item, one per unit of Qty.
+//
+// A receive with Lot books into that lot. An issue with LotNumber takes
+// from that lot; without it the item's lots are drawn first-expired-first-out
+// and only stock outside any lot may cover the rest.
+//
+// An issue with ReservationID consumes that reservation; its Qty is taken
+// from the reservation.
+type MovementRequest struct {
+	Kind       MovementKind
+	Qty        int
+	Location   *string
+	ToLocation *string
+	Reason     *string
+	Reference  *string
+	Lot        *LotInput
+	LotNumber  *string
+	Serials    []string
+
+	ReservationID *int64
+}
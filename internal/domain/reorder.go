@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// ReorderPoint holds the replenishment settings of an item, for its whole
+// stock when Location is nil or for one location. Stock at or below
+// ReorderPoint is low; below MinQty it is critical. MaxQty is the level to
+// order up to.
+type ReorderPoint struct {
+	ID           int64      `json:"id"`
+	ItemID       int64      `json:"item_id"`
+	Location     *string    `json:"location"`
+	MinQty       int        `json:"min_qty"`
+	ReorderPoint int        `json:"reorder_point"`
+	MaxQty       *int       `json:"max_qty,omitempty"`
+	AlertedAt    *time.Time `json:"alerted_at,omitempty"`
+	Created      time.Time  `json:"created_at"`
+	Updated      time.Time  `json:"updated_at"`
+}
+
+// ReorderPointInput sets the thresholds of an item at Location (nil for
+// the item as a whole). ReorderPoint defaults to MinQty.
+type ReorderPointInput struct {
+	Location     *string
+	MinQty       int
+	ReorderPoint *int
+	MaxQty       *int
+}
+
+type LowStockSeverity string
+
+const (
+	LowStockReorder  LowStockSeverity = "reorder"
+	LowStockCritical LowStockSeverity = "critical"
+)
+
+// LowStockLine is an item at or below its reorder point. Qty is the
+// available qty for item-wide settings and the stock at the location
+// otherwise.
+type LowStockLine struct {
+	ReorderPoint
+	SKU          string           `json:"sku"`
+	Name         string           `json:"name"`
+	Qty          int              `json:"qty"`
+	Severity     LowStockSeverity `json:"severity"`
+	SuggestedQty int              `json:"suggested_qty"`
+}
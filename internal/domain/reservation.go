@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+type ReservationStatus string
+
+const (
+	ReservationActive   ReservationStatus = "active"
+	ReservationReleased ReservationStatus = "released"
+	ReservationConsumed ReservationStatus = "consumed"
+
+	// an active reservation past its expires_at; it no longer holds stock
+	ReservationExpired ReservationStatus = "expired"
+)
+
+// Reservation holds qty of an item for an owner (an order, a customer) so
+// that it is not promised twice.
+type Reservation struct {
+	ID        int64             `json:"id"`
+	ItemID    int64             `json:"item_id"`
+	Qty       int               `json:"qty"`
+	Owner     string            `json:"owner"`
+	Reference *string           `json:"reference,omitempty"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	CreatedBy string            `json:"created_by"`
+	Created   time.Time         `json:"created_at"`
+	ClosedBy  *string           `json:"closed_by,omitempty"`
+	ClosedAt  *time.Time        `json:"closed_at,omitempty"`
+}
+
+type ReservationCreate struct {
+	Qty       int
+	Owner     string
+	Reference *string
+	ExpiresAt *time.Time
+	// Serials optionally picks the units of a serialized item, one per unit
+	// of Qty; they stay reserved for this reservation until it ends.
+	Serials []string
+}
+
+type ReservationQuery struct {
+	ItemID *int64
+	Status *ReservationStatus
+	Owner  *string
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+type SerialStatus string
+
+const (
+	SerialInStock  SerialStatus = "in_stock"
+	SerialReserved SerialStatus = "reserved"
+	SerialShipped  SerialStatus = "shipped"
+	SerialReturned SerialStatus = "returned"
+	SerialScrapped SerialStatus = "scrapped"
+)
+
+// OnHand reports whether a unit with the status is in the warehouse and
+// counts towards the qty of its item.
+func (s SerialStatus) OnHand() bool {
+	return s == SerialInStock || s == SerialReserved || s == SerialReturned
+}
+
+type Serial struct {
+	ID            int64        `json:"id"`
+	ItemID        int64        `json:"item_id"`
+	Serial        string       `json:"serial"`
+	Status        SerialStatus `json:"status"`
+	LocationID    *int64       `json:"location_id,omitempty"`
+	Location      *string      `json:"location,omitempty"`
+	ReservationID *int64       `json:"reservation_id,omitempty"`
+	Created       time.Time    `json:"created_at"`
+	Updated       time.Time    `json:"updated_at"`
+}
+
+// SerialLifecycle is a serial with every history entry that changed it,
+// oldest first.
+type SerialLifecycle struct {
+	Serial  Serial         `json:"serial"`
+	SKU     string         `json:"sku"`
+	History []HistoryEntry `json:"history"`
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+type RefreshToken struct {
+	ID        int64
+	FamilyID  string
+	Username  string
+	AccessJTI string
+	AccessExp time.Time
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+type TransferStatus string
+
+const (
+	TransferDraft     TransferStatus = "draft"
+	TransferInTransit TransferStatus = "in_transit"
+	TransferReceived  TransferStatus = "received"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// TransitLocation is the bin holding dispatched transfers until they are
+// received, so that stock is never booked on one side only.
+const TransitLocation = "IN-TRANSIT"
+
+type Transfer struct {
+	ID            int64          `json:"id"`
+	Status        TransferStatus `json:"status"`
+	FromLocation  string         `json:"from_location"`
+	ToLocation    string         `json:"to_location"`
+	Reference     *string        `json:"reference,omitempty"`
+	CorrelationID string         `json:"correlation_id"`
+	CreatedBy     *string        `json:"created_by,omitempty"`
+	Created       time.Time      `json:"created_at"`
+	DispatchedAt  *time.Time     `json:"dispatched_at,omitempty"`
+	ReceivedAt    *time.Time     `json:"received_at,omitempty"`
+	Lines         []TransferLine `json:"lines"`
+}
+
+type TransferLine struct {
+	ItemID int64  `json:"item_id"`
+	SKU    string `json:"sku,omitempty"`
+	Qty    int    `json:"qty"`
+}
+
+type TransferCreate struct {
+	FromLocation string
+	ToLocation   string
+	Reference    *string
+	Lines        []TransferLine
+}
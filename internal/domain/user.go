@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	Role         Role      `json:"role"`
+	Disabled     bool      `json:"disabled"`
+	PasswordHash string    `json:"-"`
+	Created      time.Time `json:"created_at"`
+	Updated      time.Time `json:"updated_at"`
+}
+
+type UserCreate struct {
+	Username string
+	Password string
+	Role     Role
+}
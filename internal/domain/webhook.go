@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEventTypes are the events a webhook can subscribe to.
+var WebhookEventTypes = []string{EventItemCreated, EventItemUpdated, EventItemQtyChanged, EventItemDeleted, EventItemRestored}
+
+type Webhook struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Created    time.Time `json:"created_at"`
+	Updated    time.Time `json:"updated_at"`
+}
+
+type WebhookInput struct {
+	URL        string
+	Secret     string
+	EventTypes []string
+	Active     bool
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	ID            int64          `json:"id"`
+	WebhookID     int64          `json:"webhook_id"`
+	EventID       string         `json:"event_id"`
+	EventType     string         `json:"event_type"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastStatus    *int           `json:"last_status,omitempty"`
+	LastError     *string        `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time     `json:"delivered_at,omitempty"`
+	Created       time.Time      `json:"created_at"`
+}
+
+// OutboundDelivery is a due delivery claimed by the dispatcher together
+// with everything needed to send it.
+type OutboundDelivery struct {
+	ID        int64
+	Attempts  int
+	URL       string
+	Secret    string
+	EventID   string
+	EventType string
+	Payload   json.RawMessage
+}
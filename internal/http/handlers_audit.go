@@ -0,0 +1,63 @@
+package http
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"time"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/service"
+)
+
+type AuditHandler struct {
+	audit *service.AuditService
+}
+
+func NewAuditHandler(audit *service.AuditService) *AuditHandler {
+	return &AuditHandler{audit: audit}
+}
+
+// Verify walks the whole history chain, which can take a while on a large
+// log, so the write deadline is lifted for it.
+func (h *AuditHandler) Verify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		rep, err := h.audit.Verify(r.Context())
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to verify history")
+			return
+		}
+		JSON(w, http.StatusOK, rep)
+	}
+}
+
+type checkpointsResponse struct {
+	PublicKey   *string             `json:"public_key"`
+	Checkpoints []domain.Checkpoint `json:"checkpoints"`
+}
+
+// Checkpoints lists the signed checkpoints together with the PEM public key
+// needed to check them offline.
+func (h *AuditHandler) Checkpoints() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cps, err := h.audit.Checkpoints(r.Context())
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to load checkpoints")
+			return
+		}
+
+		resp := checkpointsResponse{Checkpoints: cps}
+		if pub := h.audit.PublicKey(); pub != nil {
+			der, err := x509.MarshalPKIXPublicKey(pub)
+			if err != nil {
+				Fail(w, http.StatusInternalServerError, "failed to encode public key")
+				return
+			}
+			s := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			resp.PublicKey = &s
+		}
+		JSON(w, http.StatusOK, resp)
+	}
+}
@@ -1,24 +1,25 @@
 package http
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"warehouse/internal/auth"
-	"warehouse/internal/domain"
+	"warehouse/internal/service"
 )
 
 type loginRequest struct {
 	Username string `json:"username"`
-	Role     string `json:"role"`
+	Password string `json:"password"`
 }
 
-type loginResponse struct {
-	Token string `json:"token"`
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
-func LoginHandler(jwtMgr *auth.Manager) http.HandlerFunc {
+func LoginHandler(users *service.UsersService, tokens *service.TokensService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req loginRequest
 		if err := DecodeJSON(r, &req); err != nil {
@@ -27,24 +28,84 @@ func LoginHandler(jwtMgr *auth.Manager) http.HandlerFunc {
 		}
 
 		req.Username = strings.TrimSpace(req.Username)
-		if req.Username == "" {
-			Fail(w, http.StatusBadRequest, "username is required")
+		if req.Username == "" || req.Password == "" {
+			Fail(w, http.StatusBadRequest, "username and password are required")
 			return
 		}
 
-		role, ok := domain.ParseRole(req.Role)
-		if !ok {
-			Fail(w, http.StatusBadRequest, "invalid role")
+		u, err := users.Authenticate(r.Context(), req.Username, req.Password)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCredentials) {
+				Fail(w, http.StatusUnauthorized, "invalid username or password")
+				return
+			}
+			if errors.Is(err, service.ErrUserDisabled) {
+				Fail(w, http.StatusForbidden, "user is disabled")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to authenticate")
 			return
 		}
 
-		token, err := jwtMgr.Generate(req.Username, role.String(), 24*time.Hour)
+		pair, err := tokens.Issue(r.Context(), u)
 		if err != nil {
 			Fail(w, http.StatusInternalServerError, "failed to generate token")
 			return
 		}
 
-		JSON(w, http.StatusOK, loginResponse{Token: token})
+		JSON(w, http.StatusOK, pair)
+	}
+}
+
+func RefreshHandler(tokens *service.TokensService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req refreshRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+		if req.RefreshToken == "" {
+			Fail(w, http.StatusBadRequest, "refresh_token is required")
+			return
+		}
+
+		pair, err := tokens.Refresh(r.Context(), req.RefreshToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidRefreshToken),
+				errors.Is(err, service.ErrRefreshTokenReused),
+				errors.Is(err, service.ErrUserDisabled):
+				Fail(w, http.StatusUnauthorized, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to refresh token")
+			}
+			return
+		}
+
+		JSON(w, http.StatusOK, pair)
+	}
+}
+
+func LogoutHandler(tokens *service.TokensService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		// the body is optional; chunked requests have no ContentLength, so
+		// an empty one only shows as io.EOF
+		var req refreshRequest
+		if err := DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		if err := tokens.Logout(r.Context(), p.Username, p.JTI, p.ExpiresAt, strings.TrimSpace(req.RefreshToken)); err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to logout")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
@@ -57,3 +118,10 @@ func MeHandler() http.HandlerFunc {
 		})
 	}
 }
+
+func JWKSHandler(jwtMgr *auth.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		JSON(w, http.StatusOK, jwtMgr.JWKS())
+	}
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/service"
+	"warehouse/internal/ws"
+)
+
+// heartbeat keeps idle feed connections open through proxies.
+const heartbeat = 25 * time.Second
+
+type EventsHandler struct {
+	events *service.EventsService
+}
+
+func NewEventsHandler(events *service.EventsService) *EventsHandler {
+	return &EventsHandler{events: events}
+}
+
+// Stream is the live item change feed: Server-Sent Events by default, or a
+// WebSocket when the request asks for an upgrade. Event ids are chain seq
+// numbers; both resume after the Last-Event-ID header or the last_event_id
+// query parameter.
+func (h *EventsHandler) Stream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		filter, err := parseEventFilter(r)
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var lastSeq int64
+		if v := strings.TrimSpace(r.Header.Get("Last-Event-ID")); v != "" {
+			lastSeq, err = strconv.ParseInt(v, 10, 64)
+		} else if v := strings.TrimSpace(r.URL.Query().Get("last_event_id")); v != "" {
+			lastSeq, err = strconv.ParseInt(v, 10, 64)
+		}
+		if err != nil || lastSeq < 0 {
+			Fail(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+
+		// subscribe before replaying so nothing committed in between is lost
+		sub := h.events.Subscribe()
+		defer sub.Close()
+
+		var replay []domain.HistoryEntry
+		full := true
+		if lastSeq > 0 {
+			if replay, full, err = h.events.Replay(r.Context(), lastSeq); err != nil {
+				Fail(w, http.StatusInternalServerError, "failed to load missed events")
+				return
+			}
+		}
+
+		var out eventSink
+		if ws.IsUpgrade(r) {
+			conn, err := ws.Upgrade(w, r)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			defer conn.Close()
+			out = wsSink{conn}
+		} else {
+			rc := http.NewResponseController(w)
+			_ = rc.SetWriteDeadline(time.Time{})
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			out = sseSink{w: w, rc: rc, done: r.Context().Done()}
+		}
+
+		if !full {
+			if out.send(0, "reset", map[string]string{"reason": "too many missed events, reload"}) != nil {
+				return
+			}
+		}
+
+		seen := make(map[int64]bool, len(replay))
+		for _, e := range replay {
+			seen[e.ID] = true
+			if !service.MatchEvent(e, filter) {
+				continue
+			}
+			ev := service.ItemEvent(e, p.Role)
+			if out.send(ev.Seq, ev.Type, ev) != nil {
+				return
+			}
+		}
+
+		ping := time.NewTicker(heartbeat)
+		defer ping.Stop()
+		for {
+			select {
+			case <-out.closed():
+				return
+			case <-ping.C:
+				if out.ping() != nil {
+					return
+				}
+			case e, ok := <-sub.C:
+				if !ok {
+					// fell too far behind; the client resumes with Last-Event-ID
+					return
+				}
+				if seen[e.ID] || !service.MatchEvent(e, filter) {
+					continue
+				}
+				ev := service.ItemEvent(e, p.Role)
+				if out.send(ev.Seq, ev.Type, ev) != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func parseEventFilter(r *http.Request) (domain.EventFilter, error) {
+	q := r.URL.Query()
+	var f domain.EventFilter
+	if v := strings.TrimSpace(q.Get("item_id")); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			return domain.EventFilter{}, errBad("invalid item_id")
+		}
+		f.ItemID = &id
+	}
+	if v := strings.TrimSpace(q.Get("location")); v != "" {
+		f.Location = &v
+	}
+	return f, nil
+}
+
+type eventSink interface {
+	send(id int64, typ string, v any) error
+	ping() error
+	closed() <-chan struct{}
+}
+
+type sseSink struct {
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	done <-chan struct{}
+}
+
+func (s sseSink) send(id int64, typ string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg := "event: " + typ + "\ndata: " + string(b) + "\n\n"
+	if id > 0 {
+		msg = "id: " + itoa64(id) + "\n" + msg
+	}
+	if _, err := s.w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s sseSink) ping() error {
+	if _, err := s.w.Write([]byte(": ping\n\n")); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s sseSink) closed() <-chan struct{} { return s.done }
+
+type wsSink struct {
+	conn *ws.Conn
+}
+
+func (s wsSink) send(id int64, typ string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		b, err = json.Marshal(map[string]any{"type": typ, "data": v})
+		if err != nil {
+			return err
+		}
+	}
+	return s.conn.WriteText(b)
+}
+
+func (s wsSink) ping() error             { return s.conn.Ping() }
+func (s wsSink) closed() <-chan struct{} { return s.conn.Done() }
@@ -1,9 +1,9 @@
 package http
 
 import (
-	"bytes"
-	"encoding/csv"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,10 +15,11 @@ import (
 
 type HistoryHandler struct {
 	history *service.HistoryService
+	logger  *slog.Logger
 }
 
-func NewHistoryHandler(history *service.HistoryService) *HistoryHandler {
-	return &HistoryHandler{history: history}
+func NewHistoryHandler(history *service.HistoryService, logger *slog.Logger) *HistoryHandler {
+	return &HistoryHandler{history: history, logger: logger}
 }
 
 func (h *HistoryHandler) ListByItem() http.HandlerFunc {
@@ -47,7 +48,62 @@ func (h *HistoryHandler) ListByItem() http.HandlerFunc {
 	}
 }
 
-func (h *HistoryHandler) ExportCSV() http.HandlerFunc {
+type historyListResponse struct {
+	Entries    []domain.HistoryEntry `json:"entries"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+// List is the warehouse-wide audit log. Unlike ListByItem it also accepts
+// item_id and sku filters and is paginated.
+func (h *HistoryHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		filter, err := parseGlobalHistoryFilter(r)
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		limit := defaultPageSize
+		if v := strings.TrimSpace(q.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxPageSize {
+				Fail(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+				return
+			}
+			limit = n
+		}
+
+		var after *domain.HistoryCursor
+		if v := strings.TrimSpace(q.Get("cursor")); v != "" {
+			var c domain.HistoryCursor
+			if err := decodeCursor(v, &c); err != nil {
+				Fail(w, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			after = &c
+		}
+
+		includeChanges := q.Get("includeChanges") == "1"
+
+		page, err := h.history.List(r.Context(), filter, after, limit, includeChanges)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to load history")
+			return
+		}
+
+		resp := historyListResponse{Entries: page.Entries}
+		if page.Next != nil {
+			c := encodeCursor(page.Next)
+			resp.NextCursor = &c
+		}
+		JSON(w, http.StatusOK, resp)
+	}
+}
+
+// Export streams the history of one item as csv, ndjson or xlsx.
+func (h *HistoryHandler) Export() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		itemID, err := parseID(chi.URLParam(r, "id"))
 		if err != nil {
@@ -60,45 +116,55 @@ func (h *HistoryHandler) ExportCSV() http.HandlerFunc {
 			Fail(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		filter.ItemID = &itemID
+
+		h.exportHistory(w, r, filter, "history_item_"+itoa64(itemID))
+	}
+}
 
-		entries, err := h.history.ListByItem(r.Context(), itemID, filter, false)
+// ExportAll streams the global audit log with the same filters as List.
+func (h *HistoryHandler) ExportAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseGlobalHistoryFilter(r)
 		if err != nil {
-			Fail(w, http.StatusInternalServerError, "failed to load history")
+			Fail(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		var buf bytes.Buffer
-		cw := csv.NewWriter(&buf)
-		_ = cw.Write([]string{"id", "item_id", "action", "actor", "actor_role", "changed_at", "old_data", "new_data"})
-		for _, e := range entries {
-			actor := ""
-			if e.Actor != nil {
-				actor = *e.Actor
-			}
-			role := ""
-			if e.ActorRole != nil {
-				role = *e.ActorRole
-			}
-			oldStr := compactJSON(e.OldData)
-			newStr := compactJSON(e.NewData)
-			_ = cw.Write([]string{
-				itoa64(e.ID),
-				itoa64(e.ItemID),
-				e.Action,
-				actor,
-				role,
-				e.ChangedAt.Format(time.RFC3339),
-				oldStr,
-				newStr,
-			})
-		}
-		cw.Flush()
-
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Content-Disposition", "attachment; filename=history_item_"+itoa64(itemID)+".csv")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(buf.Bytes())
+		h.exportHistory(w, r, filter, "history")
+	}
+}
+
+// Snapshot rebuilds the whole inventory, deleted items included, as it was
+// at as_of.
+func (h *HistoryHandler) Snapshot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := strings.TrimSpace(r.URL.Query().Get("as_of"))
+		if v == "" {
+			Fail(w, http.StatusBadRequest, "as_of is required")
+			return
+		}
+		asOf, err := parseAsOf(v)
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		snap, err := h.history.SnapshotAsOf(r.Context(), asOf)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to build snapshot")
+			return
+		}
+		JSON(w, http.StatusOK, snap)
+	}
+}
+
+func parseAsOf(v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errBad("as_of must be RFC3339")
 	}
+	return t, nil
 }
 
 func parseHistoryFilter(r *http.Request) (domain.HistoryFilter, error) {
@@ -129,6 +195,30 @@ func parseHistoryFilter(r *http.Request) (domain.HistoryFilter, error) {
 	return f, nil
 }
 
+// parseGlobalHistoryFilter adds the item_id and sku filters of the global log.
+func parseGlobalHistoryFilter(r *http.Request) (domain.HistoryFilter, error) {
+	f, err := parseHistoryFilter(r)
+	if err != nil {
+		return domain.HistoryFilter{}, err
+	}
+
+	q := r.URL.Query()
+	if v := strings.TrimSpace(q.Get("item_id")); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			return domain.HistoryFilter{}, errBad("invalid item_id")
+		}
+		f.ItemID = &id
+	}
+	if v := strings.TrimSpace(q.Get("sku")); v != "" {
+		f.SKU = &v
+	}
+	if v := strings.TrimSpace(q.Get("batch_id")); v != "" {
+		f.BatchID = &v
+	}
+	return f, nil
+}
+
 type badErr string
 
 func (e badErr) Error() string { return string(e) }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -15,11 +16,12 @@ import (
 )
 
 type ItemsHandler struct {
-	items *service.ItemsService
+	items   *service.ItemsService
+	history *service.HistoryService
 }
 
-func NewItemsHandler(items *service.ItemsService) *ItemsHandler {
-	return &ItemsHandler{items: items}
+func NewItemsHandler(items *service.ItemsService, history *service.HistoryService) *ItemsHandler {
+	return &ItemsHandler{items: items, history: history}
 }
 
 type itemUpsertRequest struct {
@@ -27,18 +29,228 @@ type itemUpsertRequest struct {
 	Name     string  `json:"name"`
 	Qty      int     `json:"qty"`
 	Location *string `json:"location"`
+
+	// only on create; PUT /items/{id}/tracking switches it later
+	Tracking domain.Tracking `json:"tracking"`
+}
+
+// normalize trims the fields and checks the ones every write requires.
+func (req *itemUpsertRequest) normalize() error {
+	req.SKU = strings.TrimSpace(req.SKU)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.SKU == "" || req.Name == "" {
+		return errBad("sku and name are required")
+	}
+	if req.Qty < 0 {
+		return errBad("qty must be >= 0")
+	}
+	req.Location = trimOptional(req.Location)
+	switch req.Tracking {
+	case "", domain.TrackingNone, domain.TrackingLot, domain.TrackingSerial:
+	default:
+		return errBad("tracking must be one of none, lot, serial")
+	}
+	return nil
+}
+
+type itemListResponse struct {
+	Items      []domain.Item `json:"items"`
+	NextCursor *string       `json:"next_cursor"`
 }
 
 func (h *ItemsHandler) List() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		search := r.URL.Query().Get("search")
-		items, err := h.items.List(r.Context(), search)
+		q, err := parseItemQuery(r)
 		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if q.IncludeDeleted && !isAdmin(r) {
+			Fail(w, http.StatusForbidden, "only admin may list deleted items")
+			return
+		}
+
+		page, err := h.items.List(r.Context(), q)
+		if err != nil {
+			if errors.Is(err, repo.ErrInvalidCursor) {
+				Fail(w, http.StatusBadRequest, "invalid cursor")
+				return
+			}
 			Fail(w, http.StatusInternalServerError, "failed to list items")
 			return
 		}
-		JSON(w, http.StatusOK, items)
+
+		resp := itemListResponse{Items: page.Items}
+		if page.Next != nil {
+			c := encodeCursor(page.Next)
+			resp.NextCursor = &c
+		}
+
+		w.Header().Set("X-Total-Count", itoa64(page.Total))
+		JSON(w, http.StatusOK, resp)
+	}
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+func parseItemQuery(r *http.Request) (domain.ItemQuery, error) {
+	v := r.URL.Query()
+	q := domain.ItemQuery{
+		Search: v.Get("search"),
+		Sort:   domain.ItemSortID,
+		Limit:  defaultPageSize,
+	}
+
+	if s := strings.TrimSpace(v.Get("sort")); s != "" {
+		switch domain.ItemSort(s) {
+		case domain.ItemSortID, domain.ItemSortSKU, domain.ItemSortName, domain.ItemSortQty, domain.ItemSortUpdatedAt:
+			q.Sort = domain.ItemSort(s)
+		default:
+			return domain.ItemQuery{}, errBad("sort must be one of id, sku, name, qty, updated_at")
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(v.Get("order"))) {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return domain.ItemQuery{}, errBad("order must be asc or desc")
+	}
+
+	if s := strings.TrimSpace(v.Get("limit")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxPageSize {
+			return domain.ItemQuery{}, errBad("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		q.Limit = n
+	}
+
+	if s := strings.TrimSpace(v.Get("qty_lt")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return domain.ItemQuery{}, errBad("qty_lt must be an integer")
+		}
+		q.QtyLT = &n
 	}
+	if s := strings.TrimSpace(v.Get("location")); s != "" {
+		q.Location = &s
+	}
+	if s := strings.TrimSpace(v.Get("updated_since")); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return domain.ItemQuery{}, errBad("updated_since must be RFC3339")
+		}
+		q.UpdatedSince = &t
+	}
+
+	q.IncludeDeleted = includeDeleted(r)
+
+	if s := strings.TrimSpace(v.Get("cursor")); s != "" {
+		var c domain.ItemCursor
+		if err := decodeCursor(s, &c); err != nil || c.Sort != q.Sort || c.Desc != q.Desc {
+			return domain.ItemQuery{}, errBad("invalid cursor")
+		}
+		q.After = &c
+	}
+
+	return q, nil
+}
+
+func (h *ItemsHandler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		if v := strings.TrimSpace(r.URL.Query().Get("as_of")); v != "" {
+			asOf, err := parseAsOf(v)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			h.getAsOf(w, r, id, asOf)
+			return
+		}
+
+		if includeDeleted(r) {
+			if !isAdmin(r) {
+				Fail(w, http.StatusForbidden, "only admin may see deleted items")
+				return
+			}
+			it, err := h.items.GetWithDeleted(r.Context(), id)
+			writeItem(w, r, it, err)
+			return
+		}
+
+		it, err := h.items.Get(r.Context(), id)
+		writeItem(w, r, it, err)
+	}
+}
+
+func includeDeleted(r *http.Request) bool {
+	v := r.URL.Query().Get("include_deleted")
+	return v == "1" || v == "true"
+}
+
+func isAdmin(r *http.Request) bool {
+	p, _ := PrincipalFromContext(r.Context())
+	return p.Role == domain.RoleAdmin
+}
+
+// getAsOf answers with the item rebuilt from history. No ETag is sent: a
+// past state can't be used as a precondition for writes.
+func (h *ItemsHandler) getAsOf(w http.ResponseWriter, r *http.Request, id int64, asOf time.Time) {
+	it, err := h.history.ItemAsOf(r.Context(), id, asOf)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			Fail(w, http.StatusNotFound, "item did not exist at as_of")
+			return
+		}
+		Fail(w, http.StatusInternalServerError, "failed to load item")
+		return
+	}
+	JSON(w, http.StatusOK, it)
+}
+
+func (h *ItemsHandler) GetBySKU() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sku := strings.TrimSpace(chi.URLParam(r, "sku"))
+		if sku == "" {
+			Fail(w, http.StatusBadRequest, "invalid sku")
+			return
+		}
+
+		it, err := h.items.GetBySKU(r.Context(), sku)
+		writeItem(w, r, it, err)
+	}
+}
+
+// writeItem sends a single item with its ETag and answers 304 when the
+// client already has the current representation.
+func writeItem(w http.ResponseWriter, r *http.Request, it domain.Item, err error) {
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			Fail(w, http.StatusNotFound, "item not found")
+			return
+		}
+		Fail(w, http.StatusInternalServerError, "failed to load item")
+		return
+	}
+
+	tag := itemETag(it)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	JSON(w, http.StatusOK, it)
 }
 
 func (h *ItemsHandler) Create() http.HandlerFunc {
@@ -51,39 +263,36 @@ func (h *ItemsHandler) Create() http.HandlerFunc {
 			return
 		}
 
-		req.SKU = strings.TrimSpace(req.SKU)
-		req.Name = strings.TrimSpace(req.Name)
-		if req.SKU == "" || req.Name == "" {
-			Fail(w, http.StatusBadRequest, "sku and name are required")
-			return
-		}
-		if req.Qty < 0 {
-			Fail(w, http.StatusBadRequest, "qty must be >= 0")
+		if err := req.normalize(); err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		if req.Location != nil {
-			loc := strings.TrimSpace(*req.Location)
-			req.Location = &loc
-			if loc == "" {
-				req.Location = nil
-			}
-		}
 
 		it, err := h.items.Create(r.Context(), p.Username, p.Role.String(), domain.ItemCreate{
 			SKU:      req.SKU,
 			Name:     req.Name,
 			Qty:      req.Qty,
 			Location: req.Location,
+			Tracking: req.Tracking,
 		})
 		if err != nil {
 			if isUniqueViolation(err) {
 				Fail(w, http.StatusConflict, "sku must be unique")
 				return
 			}
+			if errors.Is(err, service.ErrSerialTracked) {
+				Fail(w, http.StatusConflict, "serialized items start with qty 0 and are received by serial")
+				return
+			}
+			if errors.Is(err, service.ErrReservedLocation) {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			Fail(w, http.StatusInternalServerError, "failed to create item")
 			return
 		}
 
+		w.Header().Set("ETag", itemETag(it))
 		JSON(w, http.StatusCreated, it)
 	}
 }
@@ -104,43 +313,103 @@ func (h *ItemsHandler) Update() http.HandlerFunc {
 			return
 		}
 
-		req.SKU = strings.TrimSpace(req.SKU)
-		req.Name = strings.TrimSpace(req.Name)
-		if req.SKU == "" || req.Name == "" {
-			Fail(w, http.StatusBadRequest, "sku and name are required")
+		if err := req.normalize(); err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		if req.Qty < 0 {
-			Fail(w, http.StatusBadRequest, "qty must be >= 0")
+		if req.Tracking != "" {
+			Fail(w, http.StatusBadRequest, "tracking is changed via PUT /api/items/{id}/tracking")
 			return
 		}
-		if req.Location != nil {
-			loc := strings.TrimSpace(*req.Location)
-			req.Location = &loc
-			if loc == "" {
-				req.Location = nil
-			}
-		}
+
+		version := ifMatchVersion(r.Header.Get("If-Match"), id)
 
 		it, err := h.items.Update(r.Context(), p.Username, p.Role.String(), id, domain.ItemUpdate{
 			SKU:      req.SKU,
 			Name:     req.Name,
 			Qty:      req.Qty,
 			Location: req.Location,
-		})
+		}, version)
 		if err != nil {
 			if errors.Is(err, repo.ErrNotFound) {
 				Fail(w, http.StatusNotFound, "item not found")
 				return
 			}
+			if errors.Is(err, repo.ErrVersionMismatch) {
+				h.failStale(w, r, id)
+				return
+			}
 			if isUniqueViolation(err) {
 				Fail(w, http.StatusConflict, "sku must be unique")
 				return
 			}
+			if errors.Is(err, service.ErrSerialTracked) || errors.Is(err, service.ErrNotAvailable) || errors.Is(err, service.ErrMultiLocation) ||
+				errors.Is(err, service.ErrBelowLots) {
+				Fail(w, http.StatusConflict, err.Error())
+				return
+			}
+			if errors.Is(err, service.ErrReservedLocation) {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			Fail(w, http.StatusInternalServerError, "failed to update item")
 			return
 		}
 
+		w.Header().Set("ETag", itemETag(it))
+		JSON(w, http.StatusOK, it)
+	}
+}
+
+func (h *ItemsHandler) Patch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		patch, err := decodeItemPatch(r)
+		if err != nil {
+			if errors.Is(err, errUnsupportedPatch) {
+				w.Header().Set("Accept-Patch", mediaMergePatch+", "+mediaJSONPatch)
+				Fail(w, http.StatusUnsupportedMediaType, err.Error())
+				return
+			}
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		version := ifMatchVersion(r.Header.Get("If-Match"), id)
+
+		it, err := h.items.Patch(r.Context(), p.Username, p.Role.String(), id, version, patch)
+		if err != nil {
+			var bad badErr
+			switch {
+			case errors.As(err, &bad):
+				Fail(w, http.StatusBadRequest, bad.Error())
+			case errors.Is(err, errPatchTestFailed):
+				Fail(w, http.StatusConflict, err.Error())
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "item not found")
+			case errors.Is(err, repo.ErrVersionMismatch):
+				h.failStale(w, r, id)
+			case isUniqueViolation(err):
+				Fail(w, http.StatusConflict, "sku must be unique")
+			case errors.Is(err, service.ErrSerialTracked), errors.Is(err, service.ErrNotAvailable), errors.Is(err, service.ErrMultiLocation),
+				errors.Is(err, service.ErrBelowLots):
+				Fail(w, http.StatusConflict, err.Error())
+			case errors.Is(err, service.ErrReservedLocation):
+				Fail(w, http.StatusBadRequest, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to update item")
+			}
+			return
+		}
+
+		w.Header().Set("ETag", itemETag(it))
 		JSON(w, http.StatusOK, it)
 	}
 }
@@ -155,11 +424,17 @@ func (h *ItemsHandler) Delete() http.HandlerFunc {
 			return
 		}
 
-		if err := h.items.Delete(r.Context(), p.Username, p.Role.String(), id); err != nil {
+		version := ifMatchVersion(r.Header.Get("If-Match"), id)
+
+		if err := h.items.Delete(r.Context(), p.Username, p.Role.String(), id, version); err != nil {
 			if errors.Is(err, repo.ErrNotFound) {
 				Fail(w, http.StatusNotFound, "item not found")
 				return
 			}
+			if errors.Is(err, repo.ErrVersionMismatch) {
+				h.failStale(w, r, id)
+				return
+			}
 			Fail(w, http.StatusInternalServerError, "failed to delete item")
 			return
 		}
@@ -168,11 +443,179 @@ func (h *ItemsHandler) Delete() http.HandlerFunc {
 	}
 }
 
+// Restore takes an item out of the trash.
+func (h *ItemsHandler) Restore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		version := ifMatchVersion(r.Header.Get("If-Match"), id)
+
+		it, err := h.items.Restore(r.Context(), p.Username, p.Role.String(), id, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "item not found")
+			case errors.Is(err, service.ErrNotDeleted):
+				Fail(w, http.StatusConflict, err.Error())
+			case errors.Is(err, repo.ErrVersionMismatch):
+				Fail(w, http.StatusPreconditionFailed, "item was modified")
+			case isUniqueViolation(err):
+				Fail(w, http.StatusConflict, "sku is taken by another item")
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to restore item")
+			}
+			return
+		}
+
+		w.Header().Set("ETag", itemETag(it))
+		JSON(w, http.StatusOK, it)
+	}
+}
+
+type trackingRequest struct {
+	Tracking domain.Tracking `json:"tracking"`
+}
+
+// SetTracking switches the tracking mode of an item. Switching to serial
+// needs the serials on hand to match qty.
+func (h *ItemsHandler) SetTracking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req trackingRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		switch req.Tracking {
+		case domain.TrackingNone, domain.TrackingLot, domain.TrackingSerial:
+		default:
+			Fail(w, http.StatusBadRequest, "tracking must be one of none, lot, serial")
+			return
+		}
+
+		version := ifMatchVersion(r.Header.Get("If-Match"), id)
+
+		it, err := h.items.SetTracking(r.Context(), p.Username, p.Role.String(), id, req.Tracking, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "item not found")
+			case errors.Is(err, repo.ErrVersionMismatch):
+				h.failStale(w, r, id)
+			case errors.Is(err, service.ErrSerialCount):
+				Fail(w, http.StatusConflict, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to set tracking")
+			}
+			return
+		}
+
+		w.Header().Set("ETag", itemETag(it))
+		JSON(w, http.StatusOK, it)
+	}
+}
+
+type staleResponse struct {
+	Error   string      `json:"error"`
+	Current domain.Item `json:"current"`
+}
+
+// failStale answers a failed If-Match with 412 and the current item so the
+// client can merge its edit without another round trip.
+func (h *ItemsHandler) failStale(w http.ResponseWriter, r *http.Request, id int64) {
+	it, err := h.items.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			Fail(w, http.StatusNotFound, "item not found")
+			return
+		}
+		Fail(w, http.StatusInternalServerError, "failed to load item")
+		return
+	}
+
+	w.Header().Set("ETag", itemETag(it))
+	JSON(w, http.StatusPreconditionFailed, staleResponse{Error: "item was modified", Current: it})
+}
+
 func parseID(s string) (int64, error) {
 	s = strings.TrimSpace(s)
 	return strconv.ParseInt(s, 10, 64)
 }
 
+// Revert restores the item to a snapshot from its history: old_data by
+// default (undo the change, undelete), new_data with ?snapshot=new.
+func (h *ItemsHandler) Revert() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+		historyID, err := parseID(chi.URLParam(r, "historyId"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid history id")
+			return
+		}
+
+		var useNew bool
+		switch r.URL.Query().Get("snapshot") {
+		case "", "old":
+		case "new":
+			useNew = true
+		default:
+			Fail(w, http.StatusBadRequest, "snapshot must be old or new")
+			return
+		}
+
+		version := ifMatchVersion(r.Header.Get("If-Match"), id)
+
+		it, err := h.items.Revert(r.Context(), p.Username, p.Role.String(), id, historyID, useNew, version)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "history entry not found")
+				return
+			}
+			if errors.Is(err, service.ErrNothingToRevert) {
+				Fail(w, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+			if errors.Is(err, repo.ErrVersionMismatch) {
+				h.failStale(w, r, id)
+				return
+			}
+			if isUniqueViolation(err) {
+				Fail(w, http.StatusConflict, "sku is taken by another item")
+				return
+			}
+			if errors.Is(err, service.ErrSerialTracked) || errors.Is(err, service.ErrNotAvailable) || errors.Is(err, service.ErrMultiLocation) ||
+				errors.Is(err, service.ErrBelowLots) || errors.Is(err, service.ErrReservedLocation) {
+				Fail(w, http.StatusConflict, err.Error())
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to revert item")
+			return
+		}
+
+		w.Header().Set("ETag", itemETag(it))
+		JSON(w, http.StatusOK, it)
+	}
+}
+
 func isUniqueViolation(err error) bool {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type LocationsHandler struct {
+	locations *service.LocationsService
+	items     *service.ItemsService
+}
+
+func NewLocationsHandler(locations *service.LocationsService, items *service.ItemsService) *LocationsHandler {
+	return &LocationsHandler{locations: locations, items: items}
+}
+
+type locationCreateRequest struct {
+	ParentID *int64  `json:"parent_id"`
+	Kind     string  `json:"kind"`
+	Code     string  `json:"code"`
+	Name     *string `json:"name"`
+}
+
+func (h *LocationsHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var parentID *int64
+		if v := strings.TrimSpace(q.Get("parent_id")); v != "" {
+			id, err := parseID(v)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, "invalid parent_id")
+				return
+			}
+			parentID = &id
+		}
+
+		var kind *domain.LocationKind
+		if v := strings.TrimSpace(q.Get("kind")); v != "" {
+			k := domain.LocationKind(v)
+			kind = &k
+		}
+
+		list, err := h.locations.List(r.Context(), parentID, kind)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list locations")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+func (h *LocationsHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req locationCreateRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		req.Code = strings.TrimSpace(req.Code)
+		if req.Code == "" {
+			Fail(w, http.StatusBadRequest, "code is required")
+			return
+		}
+		kind := domain.LocationKind(strings.ToLower(strings.TrimSpace(req.Kind)))
+		if kind.Depth() < 0 {
+			Fail(w, http.StatusBadRequest, "kind must be warehouse, zone, aisle or bin")
+			return
+		}
+
+		l, err := h.locations.Create(r.Context(), domain.LocationCreate{
+			ParentID: req.ParentID,
+			Kind:     kind,
+			Code:     req.Code,
+			Name:     trimOptional(req.Name),
+		})
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidParent) || errors.Is(err, service.ErrReservedLocation) {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if isUniqueViolation(err) {
+				Fail(w, http.StatusConflict, "code must be unique")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to create location")
+			return
+		}
+
+		JSON(w, http.StatusCreated, l)
+	}
+}
+
+func (h *LocationsHandler) Stock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		st, err := h.locations.Stock(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "location not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load stock")
+			return
+		}
+		JSON(w, http.StatusOK, st)
+	}
+}
+
+func (h *LocationsHandler) ItemStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		if _, err := h.items.Get(r.Context(), id); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "item not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load item")
+			return
+		}
+
+		st, err := h.locations.ItemStock(r.Context(), id)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to load stock")
+			return
+		}
+		JSON(w, http.StatusOK, st)
+	}
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type LotsHandler struct {
+	lots  *service.LotsService
+	items *service.ItemsService
+}
+
+func NewLotsHandler(lots *service.LotsService, items *service.ItemsService) *LotsHandler {
+	return &LotsHandler{lots: lots, items: items}
+}
+
+// ItemLots lists the lots of an item in FEFO order; empty lots only with
+// include_empty=1.
+func (h *LotsHandler) ItemLots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		if _, err := h.items.Get(r.Context(), id); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "item not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load item")
+			return
+		}
+
+		v := r.URL.Query().Get("include_empty")
+		lots, err := h.lots.ListByItem(r.Context(), id, v == "1" || v == "true")
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list lots")
+			return
+		}
+		JSON(w, http.StatusOK, lots)
+	}
+}
+
+type expiringLotsResponse struct {
+	Until string               `json:"until"`
+	Lots  []domain.ExpiringLot `json:"lots"`
+}
+
+// Expiring reports the lots with stock expiring within ?within= (30d by
+// default), expired ones included.
+func (h *LotsHandler) Expiring() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		within := 30 * 24 * time.Hour
+		if v := strings.TrimSpace(r.URL.Query().Get("within")); v != "" {
+			d, err := parseDays(v)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, "within must be like 30d or 720h")
+				return
+			}
+			within = d
+		}
+
+		lots, until, err := h.lots.Expiring(r.Context(), within)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list lots")
+			return
+		}
+		JSON(w, http.StatusOK, expiringLotsResponse{Until: until.Format(time.DateOnly), Lots: lots})
+	}
+}
+
+// parseDays accepts a number of days ("30d") or a Go duration.
+func parseDays(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days < 0 {
+			return 0, errBad("invalid number of days")
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errBad("invalid duration")
+	}
+	return d, nil
+}
+
+// parseDate parses an optional YYYY-MM-DD date.
+func parseDate(s *string, field string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, *s)
+	if err != nil {
+		return nil, errBad(field + " must be YYYY-MM-DD")
+	}
+	return &t, nil
+}
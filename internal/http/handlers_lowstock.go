@@ -0,0 +1,138 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type LowStockHandler struct {
+	low *service.LowStockService
+}
+
+func NewLowStockHandler(low *service.LowStockService) *LowStockHandler {
+	return &LowStockHandler{low: low}
+}
+
+type reorderPointRequest struct {
+	Location     *string `json:"location"`
+	MinQty       *int    `json:"min_qty"`
+	ReorderPoint *int    `json:"reorder_point"`
+	MaxQty       *int    `json:"max_qty"`
+}
+
+func (h *LowStockHandler) ItemPoints() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		points, err := h.low.ListByItem(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "item not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to list reorder points")
+			return
+		}
+		JSON(w, http.StatusOK, points)
+	}
+}
+
+// SetPoint creates or replaces the reorder point of the item for the given
+// location, or for the item as a whole without one.
+func (h *LowStockHandler) SetPoint() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req reorderPointRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.MinQty == nil {
+			Fail(w, http.StatusBadRequest, "min_qty is required")
+			return
+		}
+
+		p, err := h.low.Set(r.Context(), id, domain.ReorderPointInput{
+			Location:     trimOptional(req.Location),
+			MinQty:       *req.MinQty,
+			ReorderPoint: req.ReorderPoint,
+			MaxQty:       req.MaxQty,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "item not found")
+			case errors.Is(err, service.ErrInvalidThresholds), errors.Is(err, service.ErrUnknownLocation):
+				Fail(w, http.StatusBadRequest, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to save reorder point")
+			}
+			return
+		}
+		JSON(w, http.StatusOK, p)
+	}
+}
+
+// DeletePoint removes the reorder point for ?location=, the item-wide one
+// without it.
+func (h *LowStockHandler) DeletePoint() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var location *string
+		if v := strings.TrimSpace(r.URL.Query().Get("location")); v != "" {
+			location = &v
+		}
+
+		if err := h.low.Delete(r.Context(), id, location); err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "reorder point not found")
+			case errors.Is(err, service.ErrUnknownLocation):
+				Fail(w, http.StatusBadRequest, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to delete reorder point")
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Report lists the items at or below their reorder point, optionally only
+// for ?location=.
+func (h *LowStockHandler) Report() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var location *string
+		if v := strings.TrimSpace(r.URL.Query().Get("location")); v != "" {
+			location = &v
+		}
+
+		lines, err := h.low.Report(r.Context(), location)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to build low stock report")
+			return
+		}
+		JSON(w, http.StatusOK, lines)
+	}
+}
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type MovementsHandler struct {
+	movements *service.MovementsService
+}
+
+func NewMovementsHandler(movements *service.MovementsService) *MovementsHandler {
+	return &MovementsHandler{movements: movements}
+}
+
+type movementRequest struct {
+	Qty        int     `json:"qty"`
+	Location   *string `json:"location"`
+	Reason     *string `json:"reason"`
+	Reference  *string `json:"reference"`
+	ToLocation *string `json:"to_location"`
+
+	// receive books into the lot, issue takes from it
+	LotNumber      *string `json:"lot_number"`
+	ManufacturedOn *string `json:"manufactured_on"`
+	ExpiresOn      *string `json:"expires_on"`
+	Supplier       *string `json:"supplier"`
+
+	// units of a serialized item, one per qty
+	Serials []string `json:"serials"`
+}
+
+// serials trims the serial numbers and rejects empty and repeated ones.
+func (req *movementRequest) serials() error {
+	seen := make(map[string]bool, len(req.Serials))
+	for i, sn := range req.Serials {
+		sn = strings.TrimSpace(sn)
+		if sn == "" {
+			return errBad("serials must not be empty")
+		}
+		if seen[sn] {
+			return errBad("serial " + sn + " is listed twice")
+		}
+		seen[sn] = true
+		req.Serials[i] = sn
+	}
+	return nil
+}
+
+// lot validates the lot fields of the request for the movement kind.
+func (req movementRequest) lot(kind domain.MovementKind) (*domain.LotInput, error) {
+	hasDetails := req.ManufacturedOn != nil || req.ExpiresOn != nil || req.Supplier != nil
+	if req.LotNumber == nil {
+		if hasDetails {
+			return nil, errBad("lot_number is required with lot details")
+		}
+		return nil, nil
+	}
+	if kind != domain.MovementReceive && kind != domain.MovementIssue {
+		return nil, errBad("lots are only supported for receive and issue")
+	}
+	if kind == domain.MovementIssue {
+		if hasDetails {
+			return nil, errBad("lot details are only accepted on receive")
+		}
+		return nil, nil
+	}
+
+	in := domain.LotInput{Number: *req.LotNumber, Supplier: req.Supplier}
+	var err error
+	if in.ManufacturedOn, err = parseDate(req.ManufacturedOn, "manufactured_on"); err != nil {
+		return nil, err
+	}
+	if in.ExpiresOn, err = parseDate(req.ExpiresOn, "expires_on"); err != nil {
+		return nil, err
+	}
+	if in.ManufacturedOn != nil && in.ExpiresOn != nil && in.ExpiresOn.Before(*in.ManufacturedOn) {
+		return nil, errBad("expires_on must not be before manufactured_on")
+	}
+	return &in, nil
+}
+
+type movementResponse struct {
+	Item      domain.Item       `json:"item"`
+	Movements []domain.Movement `json:"movements"`
+}
+
+func (h *MovementsHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var kind *domain.MovementKind
+		if k := strings.TrimSpace(r.URL.Query().Get("kind")); k != "" {
+			mk := domain.MovementKind(k)
+			kind = &mk
+		}
+
+		list, err := h.movements.ListByItem(r.Context(), itemID, kind)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list movements")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+func (h *MovementsHandler) Book(kind domain.MovementKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		itemID, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req movementRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		req.Reason = trimOptional(req.Reason)
+		req.Reference = trimOptional(req.Reference)
+		req.Location = trimOptional(req.Location)
+		req.ToLocation = trimOptional(req.ToLocation)
+		req.LotNumber = trimOptional(req.LotNumber)
+		req.ManufacturedOn = trimOptional(req.ManufacturedOn)
+		req.ExpiresOn = trimOptional(req.ExpiresOn)
+		req.Supplier = trimOptional(req.Supplier)
+
+		switch kind {
+		case domain.MovementAdjust:
+			if req.Qty == 0 {
+				Fail(w, http.StatusBadRequest, "qty must not be 0")
+				return
+			}
+			if req.Reason == nil {
+				Fail(w, http.StatusBadRequest, "reason is required for adjustments")
+				return
+			}
+		default:
+			if req.Qty <= 0 {
+				Fail(w, http.StatusBadRequest, "qty must be > 0")
+				return
+			}
+		}
+		if kind == domain.MovementTransfer && req.ToLocation == nil {
+			Fail(w, http.StatusBadRequest, "to_location is required")
+			return
+		}
+		if req.Reason != nil {
+			reason := strings.ToLower(*req.Reason)
+			req.Reason = &reason
+		}
+		lot, err := req.lot(kind)
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := req.serials(); err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var lotNumber *string
+		if kind == domain.MovementIssue {
+			lotNumber = req.LotNumber
+		}
+
+		it, booked, err := h.movements.Apply(r.Context(), p.Username, p.Role.String(), itemID, domain.MovementRequest{
+			Kind:       kind,
+			Qty:        req.Qty,
+			Location:   req.Location,
+			Reason:     req.Reason,
+			Reference:  req.Reference,
+			ToLocation: req.ToLocation,
+			Lot:        lot,
+			LotNumber:  lotNumber,
+			Serials:    req.Serials,
+		})
+		if err != nil {
+			failMovement(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", itemETag(it))
+		JSON(w, http.StatusCreated, movementResponse{Item: it, Movements: booked})
+	}
+}
+
+func failMovement(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		Fail(w, http.StatusNotFound, "item not found")
+	case errors.Is(err, service.ErrInsufficientStock), errors.Is(err, service.ErrLotMismatch), errors.Is(err, service.ErrSerialState),
+		errors.Is(err, service.ErrSerialNotNamed), errors.Is(err, service.ErrNotAvailable), errors.Is(err, service.ErrReservationClosed), errors.Is(err, service.ErrBelowLots):
+		Fail(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrSameLocation), errors.Is(err, service.ErrUnknownLocation), errors.Is(err, service.ErrReservedLocation),
+		errors.Is(err, service.ErrUnknownLot),
+		errors.Is(err, service.ErrUnknownSerial), errors.Is(err, service.ErrSerialsRequired), errors.Is(err, service.ErrNotSerialized),
+		errors.Is(err, service.ErrLotRequired):
+		Fail(w, http.StatusBadRequest, err.Error())
+	default:
+		Fail(w, http.StatusInternalServerError, "failed to book movement")
+	}
+}
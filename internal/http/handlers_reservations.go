@@ -0,0 +1,248 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type ReservationsHandler struct {
+	items     *service.ItemsService
+	movements *service.MovementsService
+}
+
+func NewReservationsHandler(items *service.ItemsService, movements *service.MovementsService) *ReservationsHandler {
+	return &ReservationsHandler{items: items, movements: movements}
+}
+
+type reservationRequest struct {
+	ItemID    int64    `json:"item_id"`
+	Qty       int      `json:"qty"`
+	Owner     string   `json:"owner"`
+	Reference *string  `json:"reference"`
+	ExpiresAt *string  `json:"expires_at"`
+	Serials   []string `json:"serials"`
+}
+
+func (h *ReservationsHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query()
+		var q domain.ReservationQuery
+
+		if s := strings.TrimSpace(v.Get("item_id")); s != "" {
+			id, err := parseID(s)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, "invalid item_id")
+				return
+			}
+			q.ItemID = &id
+		}
+		if s := strings.TrimSpace(v.Get("status")); s != "" {
+			st := domain.ReservationStatus(s)
+			switch st {
+			case domain.ReservationActive, domain.ReservationReleased, domain.ReservationConsumed, domain.ReservationExpired:
+			default:
+				Fail(w, http.StatusBadRequest, "status must be one of active, released, consumed, expired")
+				return
+			}
+			q.Status = &st
+		}
+		if s := strings.TrimSpace(v.Get("owner")); s != "" {
+			q.Owner = &s
+		}
+
+		list, err := h.items.ListReservations(r.Context(), q)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list reservations")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+func (h *ReservationsHandler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		res, err := h.items.GetReservation(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "reservation not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load reservation")
+			return
+		}
+		JSON(w, http.StatusOK, res)
+	}
+}
+
+func (h *ReservationsHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		var req reservationRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		req.Owner = strings.TrimSpace(req.Owner)
+		req.Reference = trimOptional(req.Reference)
+		req.ExpiresAt = trimOptional(req.ExpiresAt)
+		switch {
+		case req.ItemID <= 0:
+			Fail(w, http.StatusBadRequest, "item_id is required")
+			return
+		case req.Qty <= 0:
+			Fail(w, http.StatusBadRequest, "qty must be > 0")
+			return
+		case req.Owner == "":
+			Fail(w, http.StatusBadRequest, "owner is required")
+			return
+		}
+
+		mr := movementRequest{Serials: req.Serials}
+		if err := mr.serials(); err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		in := domain.ReservationCreate{Qty: req.Qty, Owner: req.Owner, Reference: req.Reference, Serials: mr.Serials}
+		if req.ExpiresAt != nil {
+			t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, "expires_at must be RFC3339")
+				return
+			}
+			if !t.After(time.Now()) {
+				Fail(w, http.StatusBadRequest, "expires_at must be in the future")
+				return
+			}
+			in.ExpiresAt = &t
+		}
+
+		res, err := h.items.Reserve(r.Context(), p.Username, p.Role.String(), req.ItemID, in)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "item not found")
+			case errors.Is(err, service.ErrNotAvailable), errors.Is(err, service.ErrSerialState):
+				Fail(w, http.StatusConflict, err.Error())
+			case errors.Is(err, service.ErrUnknownSerial), errors.Is(err, service.ErrSerialsRequired), errors.Is(err, service.ErrNotSerialized):
+				Fail(w, http.StatusBadRequest, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to create reservation")
+			}
+			return
+		}
+		JSON(w, http.StatusCreated, res)
+	}
+}
+
+func (h *ReservationsHandler) Release() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		res, err := h.items.Release(r.Context(), p.Username, p.Role.String(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "reservation not found")
+			case errors.Is(err, service.ErrReservationClosed):
+				Fail(w, http.StatusConflict, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to release reservation")
+			}
+			return
+		}
+		JSON(w, http.StatusOK, res)
+	}
+}
+
+type consumeRequest struct {
+	Location  *string  `json:"location"`
+	Reference *string  `json:"reference"`
+	LotNumber *string  `json:"lot_number"`
+	Serials   []string `json:"serials"`
+}
+
+type consumeResponse struct {
+	Reservation domain.Reservation `json:"reservation"`
+	Item        domain.Item        `json:"item"`
+	Movements   []domain.Movement  `json:"movements"`
+}
+
+// Consume issues the reserved stock and closes the reservation in one
+// transaction.
+func (h *ReservationsHandler) Consume() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req consumeRequest
+		if err := DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		mr := movementRequest{Serials: req.Serials}
+		if err := mr.serials(); err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		res, err := h.items.GetReservation(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "reservation not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load reservation")
+			return
+		}
+
+		it, booked, err := h.movements.Apply(r.Context(), p.Username, p.Role.String(), res.ItemID, domain.MovementRequest{
+			Kind:          domain.MovementIssue,
+			Qty:           res.Qty,
+			Location:      trimOptional(req.Location),
+			Reference:     trimOptional(req.Reference),
+			LotNumber:     trimOptional(req.LotNumber),
+			Serials:       mr.Serials,
+			ReservationID: &id,
+		})
+		if err != nil {
+			failMovement(w, err)
+			return
+		}
+
+		if res, err = h.items.GetReservation(r.Context(), id); err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to load reservation")
+			return
+		}
+		w.Header().Set("ETag", itemETag(it))
+		JSON(w, http.StatusOK, consumeResponse{Reservation: res, Item: it, Movements: booked})
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type SerialsHandler struct {
+	serials *service.SerialsService
+	items   *service.ItemsService
+}
+
+func NewSerialsHandler(serials *service.SerialsService, items *service.ItemsService) *SerialsHandler {
+	return &SerialsHandler{serials: serials, items: items}
+}
+
+// ItemSerials lists the serials of an item, optionally only those with
+// ?status=.
+func (h *SerialsHandler) ItemSerials() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var status *domain.SerialStatus
+		if v := strings.TrimSpace(r.URL.Query().Get("status")); v != "" {
+			st, err := parseSerialStatus(v)
+			if err != nil {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			status = &st
+		}
+
+		if _, err := h.items.Get(r.Context(), id); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "item not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load item")
+			return
+		}
+
+		list, err := h.serials.ListByItem(r.Context(), id, status)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list serials")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+// Get returns a serial with its lifecycle: every receive, move, shipment,
+// return and scrap recorded in the item history.
+func (h *SerialsHandler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serial := strings.TrimSpace(chi.URLParam(r, "serial"))
+
+		lc, err := h.serials.Lifecycle(r.Context(), serial)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "serial not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load serial")
+			return
+		}
+		JSON(w, http.StatusOK, lc)
+	}
+}
+
+type serialStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// SetStatus puts a returned unit back in stock.
+func (h *SerialsHandler) SetStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		serial := strings.TrimSpace(chi.URLParam(r, "serial"))
+
+		var req serialStatusRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		status, err := parseSerialStatus(strings.TrimSpace(req.Status))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		sn, err := h.serials.SetStatus(r.Context(), p.Username, p.Role.String(), serial, status)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrNotFound):
+				Fail(w, http.StatusNotFound, "serial not found")
+			case errors.Is(err, service.ErrSerialTransition):
+				Fail(w, http.StatusConflict, err.Error())
+			default:
+				Fail(w, http.StatusInternalServerError, "failed to update serial")
+			}
+			return
+		}
+		JSON(w, http.StatusOK, sn)
+	}
+}
+
+func parseSerialStatus(s string) (domain.SerialStatus, error) {
+	switch st := domain.SerialStatus(s); st {
+	case domain.SerialInStock, domain.SerialReserved, domain.SerialShipped, domain.SerialReturned, domain.SerialScrapped:
+		return st, nil
+	}
+	return "", errBad("status must be one of in_stock, reserved, shipped, returned, scrapped")
+}
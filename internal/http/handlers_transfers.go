@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type TransfersHandler struct {
+	transfers *service.TransfersService
+}
+
+func NewTransfersHandler(transfers *service.TransfersService) *TransfersHandler {
+	return &TransfersHandler{transfers: transfers}
+}
+
+type transferLineRequest struct {
+	ItemID int64 `json:"item_id"`
+	Qty    int   `json:"qty"`
+}
+
+type transferCreateRequest struct {
+	FromLocation string                `json:"from_location"`
+	ToLocation   string                `json:"to_location"`
+	Reference    *string               `json:"reference"`
+	Lines        []transferLineRequest `json:"lines"`
+	Receive      bool                  `json:"receive"`
+}
+
+func (h *TransfersHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var status *domain.TransferStatus
+		if v := strings.TrimSpace(r.URL.Query().Get("status")); v != "" {
+			st := domain.TransferStatus(v)
+			status = &st
+		}
+
+		list, err := h.transfers.List(r.Context(), status)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list transfers")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+func (h *TransfersHandler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		t, err := h.transfers.Get(r.Context(), id)
+		if err != nil {
+			failTransfer(w, err)
+			return
+		}
+		JSON(w, http.StatusOK, t)
+	}
+}
+
+func (h *TransfersHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		var req transferCreateRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		req.FromLocation = strings.TrimSpace(req.FromLocation)
+		req.ToLocation = strings.TrimSpace(req.ToLocation)
+		if req.FromLocation == "" || req.ToLocation == "" {
+			Fail(w, http.StatusBadRequest, "from_location and to_location are required")
+			return
+		}
+		if len(req.Lines) == 0 {
+			Fail(w, http.StatusBadRequest, "lines are required")
+			return
+		}
+
+		lines := make([]domain.TransferLine, 0, len(req.Lines))
+		seen := map[int64]bool{}
+		for _, l := range req.Lines {
+			if l.Qty <= 0 {
+				Fail(w, http.StatusBadRequest, "line qty must be > 0")
+				return
+			}
+			if seen[l.ItemID] {
+				Fail(w, http.StatusBadRequest, "each item may appear only once")
+				return
+			}
+			seen[l.ItemID] = true
+			lines = append(lines, domain.TransferLine{ItemID: l.ItemID, Qty: l.Qty})
+		}
+
+		t, err := h.transfers.Create(r.Context(), p.Username, p.Role.String(), domain.TransferCreate{
+			FromLocation: req.FromLocation,
+			ToLocation:   req.ToLocation,
+			Reference:    trimOptional(req.Reference),
+			Lines:        lines,
+		}, req.Receive)
+		if err != nil {
+			failTransfer(w, err)
+			return
+		}
+
+		JSON(w, http.StatusCreated, t)
+	}
+}
+
+func (h *TransfersHandler) Dispatch() http.HandlerFunc {
+	return h.step(h.transfers.Dispatch)
+}
+
+func (h *TransfersHandler) Receive() http.HandlerFunc {
+	return h.step(h.transfers.Receive)
+}
+
+func (h *TransfersHandler) Cancel() http.HandlerFunc {
+	return h.step(h.transfers.Cancel)
+}
+
+type transferStep func(ctx context.Context, actor, role string, id int64) (domain.Transfer, error)
+
+func (h *TransfersHandler) step(fn transferStep) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		t, err := fn(r.Context(), p.Username, p.Role.String(), id)
+		if err != nil {
+			failTransfer(w, err)
+			return
+		}
+		JSON(w, http.StatusOK, t)
+	}
+}
+
+func failTransfer(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransferNotFound), errors.Is(err, repo.ErrNotFound):
+		Fail(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrInsufficientStock), errors.Is(err, service.ErrSerialTracked):
+		Fail(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrSameLocation), errors.Is(err, service.ErrUnknownLocation), errors.Is(err, service.ErrReservedLocation):
+		Fail(w, http.StatusBadRequest, err.Error())
+	default:
+		Fail(w, http.StatusInternalServerError, "failed to process transfer")
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type UsersHandler struct {
+	users  *service.UsersService
+	tokens *service.TokensService
+}
+
+func NewUsersHandler(users *service.UsersService, tokens *service.TokensService) *UsersHandler {
+	return &UsersHandler{users: users, tokens: tokens}
+}
+
+type userCreateRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type userRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (h *UsersHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := h.users.List(r.Context())
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list users")
+			return
+		}
+		JSON(w, http.StatusOK, users)
+	}
+}
+
+func (h *UsersHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req userCreateRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		req.Username = strings.TrimSpace(req.Username)
+		if req.Username == "" {
+			Fail(w, http.StatusBadRequest, "username is required")
+			return
+		}
+
+		role, ok := domain.ParseRole(req.Role)
+		if !ok {
+			Fail(w, http.StatusBadRequest, "invalid role")
+			return
+		}
+
+		u, err := h.users.Create(r.Context(), domain.UserCreate{
+			Username: req.Username,
+			Password: req.Password,
+			Role:     role,
+		})
+		if err != nil {
+			if errors.Is(err, service.ErrWeakPassword) {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if isUniqueViolation(err) {
+				Fail(w, http.StatusConflict, "username must be unique")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to create user")
+			return
+		}
+
+		JSON(w, http.StatusCreated, u)
+	}
+}
+
+func (h *UsersHandler) SetRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req userRoleRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		role, ok := domain.ParseRole(req.Role)
+		if !ok {
+			Fail(w, http.StatusBadRequest, "invalid role")
+			return
+		}
+
+		p, _ := PrincipalFromContext(r.Context())
+		u, err := h.users.SetRole(r.Context(), p.Username, id, role)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "user not found")
+				return
+			}
+			if errors.Is(err, service.ErrSelfLockout) || errors.Is(err, service.ErrLastAdmin) {
+				Fail(w, http.StatusConflict, err.Error())
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to update user")
+			return
+		}
+
+		JSON(w, http.StatusOK, u)
+	}
+}
+
+func (h *UsersHandler) SetDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		p, _ := PrincipalFromContext(r.Context())
+		u, err := h.users.SetDisabled(r.Context(), p.Username, id, disabled)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "user not found")
+				return
+			}
+			if errors.Is(err, service.ErrSelfLockout) || errors.Is(err, service.ErrLastAdmin) {
+				Fail(w, http.StatusConflict, err.Error())
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to update user")
+			return
+		}
+
+		JSON(w, http.StatusOK, u)
+	}
+}
+
+func (h *UsersHandler) RevokeSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		u, err := h.users.Get(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "user not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+
+		if err := h.tokens.RevokeUser(r.Context(), u.Username); err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to revoke sessions")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+type WebhooksHandler struct {
+	webhooks *service.WebhooksService
+}
+
+func NewWebhooksHandler(webhooks *service.WebhooksService) *WebhooksHandler {
+	return &WebhooksHandler{webhooks: webhooks}
+}
+
+type webhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+func (req webhookRequest) input() domain.WebhookInput {
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	return domain.WebhookInput{
+		URL:        strings.TrimSpace(req.URL),
+		Secret:     strings.TrimSpace(req.Secret),
+		EventTypes: req.EventTypes,
+		Active:     active,
+	}
+}
+
+func (h *WebhooksHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := h.webhooks.List(r.Context())
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list webhooks")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+// Create answers with the signing secret; it is not shown again.
+func (h *WebhooksHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req webhookRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		hook, err := h.webhooks.Create(r.Context(), req.input())
+		if err != nil {
+			if isWebhookInputErr(err) {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to create webhook")
+			return
+		}
+		JSON(w, http.StatusCreated, hook)
+	}
+}
+
+func (h *WebhooksHandler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req webhookRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+
+		hook, err := h.webhooks.Update(r.Context(), id, req.input())
+		if err != nil {
+			if isWebhookInputErr(err) {
+				Fail(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "webhook not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to update webhook")
+			return
+		}
+		JSON(w, http.StatusOK, hook)
+	}
+}
+
+func (h *WebhooksHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		if err := h.webhooks.Delete(r.Context(), id); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "webhook not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to delete webhook")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *WebhooksHandler) Deliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+		q := r.URL.Query()
+
+		var status *domain.DeliveryStatus
+		if v := strings.TrimSpace(q.Get("status")); v != "" {
+			st := domain.DeliveryStatus(v)
+			if st != domain.DeliveryPending && st != domain.DeliveryDelivered && st != domain.DeliveryDead {
+				Fail(w, http.StatusBadRequest, "status must be pending, delivered or dead")
+				return
+			}
+			status = &st
+		}
+
+		limit := defaultPageSize
+		if v := strings.TrimSpace(q.Get("limit")); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxPageSize {
+				Fail(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+				return
+			}
+			limit = n
+		}
+
+		list, err := h.webhooks.Deliveries(r.Context(), id, status, limit)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to list deliveries")
+			return
+		}
+		JSON(w, http.StatusOK, list)
+	}
+}
+
+func (h *WebhooksHandler) Retry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(chi.URLParam(r, "id"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+		deliveryID, err := parseID(chi.URLParam(r, "deliveryId"))
+		if err != nil {
+			Fail(w, http.StatusBadRequest, "invalid delivery id")
+			return
+		}
+
+		if err := h.webhooks.Retry(r.Context(), id, deliveryID); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				Fail(w, http.StatusNotFound, "delivery not found")
+				return
+			}
+			Fail(w, http.StatusInternalServerError, "failed to retry delivery")
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func isWebhookInputErr(err error) bool {
+	return errors.Is(err, service.ErrInvalidWebhookURL) || errors.Is(err, service.ErrInvalidWebhookType)
+}
@@ -1,14 +1,30 @@
 package http
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"strconv"
+	"strings"
+
+	"warehouse/internal/domain"
 )
 
 func itoa64(v int64) string {
 	return strconv.FormatInt(v, 10)
 }
 
+// trimOptional trims an optional string and drops it when empty.
+func trimOptional(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	v := strings.TrimSpace(*s)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
 func compactJSON(v any) string {
 	if v == nil {
 		return ""
@@ -19,3 +35,70 @@ func compactJSON(v any) string {
 	}
 	return string(b)
 }
+
+// itemETag tags the item version. Reservations don't change the version,
+// so a reserved qty is appended to keep cached reads fresh.
+func itemETag(it domain.Item) string {
+	tag := itoa64(it.ID) + "-v" + itoa64(it.Version)
+	if it.Reserved != 0 {
+		tag += "-r" + strconv.Itoa(it.Reserved)
+	}
+	return `"` + tag + `"`
+}
+
+// ifMatchVersion extracts the expected item version from an If-Match
+// header. A missing header or "*" means no version check; tags that do not
+// belong to the item yield a version no row can have, so the write fails
+// with 412 as RFC 9110 requires. The reserved part of a tag is ignored.
+func ifMatchVersion(header string, id int64) *int64 {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+	prefix := `"` + itoa64(id) + "-v"
+	for _, c := range strings.Split(header, ",") {
+		c = strings.TrimSpace(c)
+		if !strings.HasPrefix(c, prefix) || !strings.HasSuffix(c, `"`) {
+			continue
+		}
+		ver, _, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(c, prefix), `"`), "-r")
+		v, err := strconv.ParseInt(ver, 10, 64)
+		if err == nil {
+			return &v
+		}
+	}
+	never := int64(-1)
+	return &never
+}
+
+// etagMatches implements the weak comparison used by If-None-Match.
+func etagMatches(header, tag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, c := range strings.Split(header, ",") {
+		c = strings.TrimPrefix(strings.TrimSpace(c), "W/")
+		if c == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCursor makes an opaque page cursor; clients must pass it back as is.
+func encodeCursor(c any) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, dst any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/xlsx"
+)
+
+// flushEvery is how many rows are written between flushes to the client.
+const flushEvery = 500
+
+// errExportTruncated stops an xlsx export that filled the sheet; the last
+// row of the file tells the reader it was cut.
+var errExportTruncated = errors.New("export truncated at the xlsx sheet limit")
+
+type historyExporter interface {
+	Write(e domain.HistoryEntry) error
+	Flush() error
+	Close() error
+}
+
+var historyExportTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+func newHistoryExporter(format string, w io.Writer, includeChanges bool) (historyExporter, error) {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		return &rowExporter{rows: csvRows{cw}, includeChanges: includeChanges}, nil
+	case "ndjson":
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+	case "xlsx":
+		xw, err := xlsx.NewWriter(w, "history")
+		if err != nil {
+			return nil, err
+		}
+		return &rowExporter{rows: xw, includeChanges: includeChanges}, nil
+	default:
+		return nil, errBad("format must be csv, ndjson or xlsx")
+	}
+}
+
+type rowWriter interface {
+	WriteRow(cells []string) error
+	Flush() error
+	Close() error
+}
+
+// lastRowWriter is a rowWriter with a row limit that keeps one row free to
+// note that the output was cut.
+type lastRowWriter interface {
+	WriteLastRow(cells []string) error
+}
+
+type csvRows struct{ w *csv.Writer }
+
+func (c csvRows) WriteRow(cells []string) error { return c.w.Write(cells) }
+func (c csvRows) Flush() error                  { c.w.Flush(); return c.w.Error() }
+func (c csvRows) Close() error                  { return c.Flush() }
+
+// rowExporter renders entries as flat rows for csv and xlsx.
+type rowExporter struct {
+	rows           rowWriter
+	includeChanges bool
+	header         bool
+}
+
+func (x *rowExporter) writeHeader() error {
+	x.header = true
+	cols := []string{"id", "item_id", "action", "version", "actor", "actor_role", "changed_at", "old_data", "new_data", "correlation_id", "source_history_id", "batch_id"}
+	if x.includeChanges {
+		cols = append(cols, "changes")
+	}
+	return x.rows.WriteRow(cols)
+}
+
+func (x *rowExporter) Write(e domain.HistoryEntry) error {
+	if !x.header {
+		if err := x.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	version := ""
+	if e.Version != nil {
+		version = itoa64(*e.Version)
+	}
+	source := ""
+	if e.SourceID != nil {
+		source = itoa64(*e.SourceID)
+	}
+	row := []string{
+		itoa64(e.ID),
+		itoa64(e.ItemID),
+		e.Action,
+		version,
+		deref(e.Actor),
+		deref(e.ActorRole),
+		e.ChangedAt.Format(time.RFC3339),
+		compactJSON(e.OldData),
+		compactJSON(e.NewData),
+		deref(e.CorrelationID),
+		source,
+		deref(e.BatchID),
+	}
+	if x.includeChanges {
+		row = append(row, compactJSON(e.Changes))
+	}
+	err := x.rows.WriteRow(row)
+	if lw, ok := x.rows.(lastRowWriter); ok && errors.Is(err, xlsx.ErrSheetFull) {
+		note := []string{"truncated: the sheet holds " + itoa64(xlsx.MaxRows) + " rows, export the rest with a narrower filter or as csv/ndjson"}
+		if err := lw.WriteLastRow(note); err != nil {
+			return err
+		}
+		return errExportTruncated
+	}
+	return err
+}
+
+func (x *rowExporter) Flush() error { return x.rows.Flush() }
+
+func (x *rowExporter) Close() error {
+	// an empty export still gets its header row
+	if !x.header {
+		if err := x.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return x.rows.Close()
+}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (x *ndjsonExporter) Write(e domain.HistoryEntry) error { return x.enc.Encode(e) }
+func (x *ndjsonExporter) Flush() error                      { return nil }
+func (x *ndjsonExporter) Close() error                      { return nil }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// exportHistory streams every entry matching filter to the client in the
+// requested format. The server write timeout is lifted for the request, so
+// the export runs until it is done or the client goes away.
+func (h *HistoryHandler) exportHistory(w http.ResponseWriter, r *http.Request, filter domain.HistoryFilter, name string) {
+	q := r.URL.Query()
+
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := historyExportTypes[format]
+	if !ok {
+		Fail(w, http.StatusBadRequest, "format must be csv, ndjson or xlsx")
+		return
+	}
+	includeChanges := q.Get("includeChanges") == "1"
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+name+"."+format)
+	w.WriteHeader(http.StatusOK)
+
+	x, err := newHistoryExporter(format, w, includeChanges)
+	if err != nil {
+		h.logger.Error("history export failed", "format", format, "err", err)
+		return
+	}
+
+	n := 0
+	err = h.history.Stream(r.Context(), filter, includeChanges, func(e domain.HistoryEntry) error {
+		if err := x.Write(e); err != nil {
+			return err
+		}
+		n++
+		if n%flushEvery == 0 {
+			if err := x.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errExportTruncated):
+		h.logger.Warn("history export truncated", "format", format, "rows", n)
+	case err != nil:
+		// headers are gone already, a truncated body is all the client gets
+		h.logger.Error("history export failed", "format", format, "rows", n, "err", err)
+		return
+	}
+	if err := x.Close(); err != nil {
+		h.logger.Error("history export failed", "format", format, "rows", n, "err", err)
+		return
+	}
+	_ = rc.Flush()
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+	"warehouse/internal/service"
+)
+
+const maxBatchOps = 1000
+
+type batchRequest struct {
+	Ops []batchOpRequest `json:"ops"`
+}
+
+// batchOpRequest is one operation: create and update take item, patch
+// takes patch as a merge patch object or a JSON Patch array, update, patch
+// and delete take id and optionally the expected version.
+type batchOpRequest struct {
+	Op      domain.BatchOpKind `json:"op"`
+	ID      int64              `json:"id"`
+	Version *int64             `json:"version"`
+	Item    *itemUpsertRequest `json:"item"`
+	Patch   json.RawMessage    `json:"patch"`
+}
+
+type batchErrorResponse struct {
+	Error string             `json:"error"`
+	Index int                `json:"index"`
+	Op    domain.BatchOpKind `json:"op"`
+	ID    int64              `json:"id,omitempty"`
+}
+
+func (req batchOpRequest) toOp(role domain.Role) (domain.BatchOp, error) {
+	op := domain.BatchOp{Kind: req.Op, ID: req.ID, Version: req.Version}
+
+	if req.Op != domain.BatchCreate && req.ID <= 0 {
+		return op, errBad("id is required")
+	}
+
+	switch req.Op {
+	case domain.BatchCreate, domain.BatchUpdate:
+		if req.Item == nil {
+			return op, errBad("item is required")
+		}
+		in := *req.Item
+		if err := in.normalize(); err != nil {
+			return op, err
+		}
+		if req.Op == domain.BatchCreate {
+			op.Create = domain.ItemCreate{SKU: in.SKU, Name: in.Name, Qty: in.Qty, Location: in.Location, Tracking: in.Tracking}
+			return op, nil
+		}
+		if in.Tracking != "" {
+			return op, errBad("tracking is changed via PUT /api/items/{id}/tracking")
+		}
+		up := domain.ItemUpdate{SKU: in.SKU, Name: in.Name, Qty: in.Qty, Location: in.Location}
+		op.Apply = func(domain.Item) (domain.ItemUpdate, error) { return up, nil }
+
+	case domain.BatchPatch:
+		raw := bytes.TrimSpace(req.Patch)
+		var patch itemPatch
+		var err error
+		switch {
+		case bytes.HasPrefix(raw, []byte("{")):
+			var doc map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				return op, errBad("invalid merge patch")
+			}
+			patch, err = mergePatch(doc)
+		case bytes.HasPrefix(raw, []byte("[")):
+			var ops []jsonPatchOp
+			if err := json.Unmarshal(raw, &ops); err != nil {
+				return op, errBad("invalid json patch")
+			}
+			patch, err = jsonPatch(ops)
+		default:
+			return op, errBad("patch must be a merge patch object or a json patch array")
+		}
+		if err != nil {
+			return op, err
+		}
+		op.Apply = patch
+
+	case domain.BatchDelete:
+		if role != domain.RoleAdmin {
+			return op, errForbiddenOp
+		}
+
+	default:
+		return op, errBad("op must be create, update, patch or delete")
+	}
+	return op, nil
+}
+
+var errForbiddenOp = errors.New("only admin may delete items")
+
+// Batch runs create/update/patch/delete operations in one transaction and
+// returns a result per operation. When one fails nothing is written and
+// the response names the failing operation.
+func (h *ItemsHandler) Batch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		var req batchRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			Fail(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if len(req.Ops) == 0 || len(req.Ops) > maxBatchOps {
+			Fail(w, http.StatusBadRequest, "ops must hold 1 to "+strconv.Itoa(maxBatchOps)+" operations")
+			return
+		}
+
+		ops := make([]domain.BatchOp, len(req.Ops))
+		for i, o := range req.Ops {
+			op, err := o.toOp(p.Role)
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, errForbiddenOp) {
+					status = http.StatusForbidden
+				}
+				JSON(w, status, batchErrorResponse{Error: err.Error(), Index: i, Op: o.Op, ID: o.ID})
+				return
+			}
+			ops[i] = op
+		}
+
+		res, err := h.items.Batch(r.Context(), p.Username, p.Role.String(), ops)
+		if err != nil {
+			failBatch(w, err)
+			return
+		}
+		JSON(w, http.StatusOK, res)
+	}
+}
+
+// RevertBatch restores every item a batch touched to its state from before
+// the batch, as a new batch.
+func (h *ItemsHandler) RevertBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+
+		batchID := chi.URLParam(r, "batchId")
+		res, err := h.items.RevertBatch(r.Context(), p.Username, p.Role.String(), batchID)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) && !errors.As(err, new(*service.BatchError)) {
+				Fail(w, http.StatusNotFound, "batch not found")
+				return
+			}
+			failBatch(w, err)
+			return
+		}
+		JSON(w, http.StatusOK, res)
+	}
+}
+
+func failBatch(w http.ResponseWriter, err error) {
+	var be *service.BatchError
+	if !errors.As(err, &be) {
+		Fail(w, http.StatusInternalServerError, "failed to run batch")
+		return
+	}
+
+	resp := batchErrorResponse{Index: be.Index, Op: be.Op, ID: be.ItemID}
+
+	var bad badErr
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &bad):
+		status, resp.Error = http.StatusBadRequest, bad.Error()
+	case errors.Is(err, errPatchTestFailed):
+		status, resp.Error = http.StatusConflict, errPatchTestFailed.Error()
+	case errors.Is(err, repo.ErrNotFound):
+		status, resp.Error = http.StatusNotFound, "item not found"
+	case errors.Is(err, repo.ErrVersionMismatch):
+		status, resp.Error = http.StatusPreconditionFailed, "item was modified"
+	case isUniqueViolation(err):
+		status, resp.Error = http.StatusConflict, "sku must be unique"
+	case errors.Is(err, service.ErrSerialTracked):
+		status, resp.Error = http.StatusConflict, service.ErrSerialTracked.Error()
+	case errors.Is(err, service.ErrNotAvailable):
+		status, resp.Error = http.StatusConflict, service.ErrNotAvailable.Error()
+	case errors.Is(err, service.ErrMultiLocation):
+		status, resp.Error = http.StatusConflict, service.ErrMultiLocation.Error()
+	case errors.Is(err, service.ErrBelowLots):
+		status, resp.Error = http.StatusConflict, service.ErrBelowLots.Error()
+	case errors.Is(err, service.ErrReservedLocation):
+		status, resp.Error = http.StatusBadRequest, service.ErrReservedLocation.Error()
+	default:
+		resp.Error = "failed to run batch"
+	}
+	JSON(w, status, resp)
+}
@@ -0,0 +1,329 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/xlsx"
+)
+
+const (
+	maxImportBytes = 32 << 20
+	maxImportRows  = 50000
+)
+
+// importFields are the item fields an import column can be mapped to; by
+// default a column maps to the field of the same name.
+var importFields = []string{"sku", "name", "qty", "location"}
+
+// Import upserts items from a CSV or XLSX file sent as the request body or
+// as the "file" part of a multipart form.
+//
+//	format=csv|xlsx           detected from the file name, Content-Type or content
+//	delimiter=;               csv separator, detected from the header by default
+//	col_sku=Артикул           header (or 1-based column number) of a field
+//	dry_run=1                 validate and report without writing
+//	mode=atomic|best_effort   all-or-nothing (default) or write the valid rows
+func (h *ItemsHandler) Import() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		q := r.URL.Query()
+
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		mode := domain.ImportMode(strings.TrimSpace(q.Get("mode")))
+		switch mode {
+		case "":
+			mode = domain.ImportAtomic
+		case domain.ImportAtomic, domain.ImportBestEffort:
+		default:
+			Fail(w, http.StatusBadRequest, "mode must be atomic or best_effort")
+			return
+		}
+		dryRun := q.Get("dry_run") == "1" || q.Get("dry_run") == "true"
+
+		data, filename, err := readImportFile(w, r)
+		if err != nil {
+			var tooBig *http.MaxBytesError
+			if errors.As(err, &tooBig) {
+				Fail(w, http.StatusRequestEntityTooLarge, "file is larger than "+strconv.Itoa(maxImportBytes>>20)+" MB")
+				return
+			}
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		records, err := parseImportFile(data, importFormat(q.Get("format"), filename, r.Header.Get("Content-Type"), data), q.Get("delimiter"))
+		if errors.Is(err, xlsx.ErrTooManyRows) {
+			Fail(w, http.StatusRequestEntityTooLarge, "at most "+strconv.Itoa(maxImportRows)+" rows per import")
+			return
+		}
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rows, err := mapImportColumns(records, q)
+		if err != nil {
+			Fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(rows) > maxImportRows {
+			Fail(w, http.StatusRequestEntityTooLarge, "at most "+strconv.Itoa(maxImportRows)+" rows per import")
+			return
+		}
+
+		rep, err := h.items.Import(r.Context(), p.Username, p.Role.String(), rows, mode, dryRun)
+		if err != nil {
+			Fail(w, http.StatusInternalServerError, "failed to import items")
+			return
+		}
+
+		status := http.StatusOK
+		if !dryRun && !rep.Committed {
+			status = http.StatusUnprocessableEntity
+		}
+		JSON(w, status, rep)
+	}
+}
+
+func readImportFile(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct != "multipart/form-data" {
+		data, err := io.ReadAll(r.Body)
+		if err == nil && len(data) == 0 {
+			err = errBad("file is empty")
+		}
+		return data, "", err
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", errBad("invalid multipart body")
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, "", errBad("multipart body has no file part")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err := io.ReadAll(part)
+		if err == nil && len(data) == 0 {
+			err = errBad("file is empty")
+		}
+		return data, part.FileName(), err
+	}
+}
+
+func importFormat(format, filename, contentType string, data []byte) string {
+	if f := strings.ToLower(strings.TrimSpace(format)); f != "" {
+		return f
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv", ".txt":
+		return "csv"
+	case ".xlsx":
+		return "xlsx"
+	}
+	ct, _, _ := mime.ParseMediaType(contentType)
+	if ct == historyExportTypes["xlsx"] {
+		return "xlsx"
+	}
+	// xlsx is a zip archive
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return "xlsx"
+	}
+	return "csv"
+}
+
+func parseImportFile(data []byte, format, delimiter string) ([][]string, error) {
+	switch format {
+	case "xlsx":
+		// the header row comes on top of the data rows
+		rows, err := xlsx.ReadRows(bytes.NewReader(data), int64(len(data)), maxImportRows+1)
+		if errors.Is(err, xlsx.ErrTooManyRows) {
+			return nil, err
+		}
+		if errors.Is(err, xlsx.ErrTooLarge) {
+			return nil, errBad("xlsx file is too large once unpacked")
+		}
+		if err != nil {
+			return nil, errBad("invalid xlsx file")
+		}
+		return rows, nil
+	case "csv":
+		return parseImportCSV(data, delimiter)
+	default:
+		return nil, errBad("format must be csv or xlsx")
+	}
+}
+
+func parseImportCSV(data []byte, delimiter string) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, errBad("csv must be UTF-8")
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.Comma = csvDelimiter(data, delimiter)
+	if cr.Comma == 0 {
+		return nil, errBad("delimiter must be a single character")
+	}
+
+	var out [][]string
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, errBad("invalid csv: " + err.Error())
+		}
+		// keep the index equal to the line so that reports point at the
+		// right line even after multi-line cells
+		line, _ := cr.FieldPos(0)
+		for len(out) < line-1 {
+			out = append(out, nil)
+		}
+		out = append(out, rec)
+	}
+}
+
+// csvDelimiter returns the requested separator or picks the one among
+// ',', ';' and tab that occurs most in the header line.
+func csvDelimiter(data []byte, delimiter string) rune {
+	if delimiter != "" {
+		if delimiter == `\t` {
+			return '\t'
+		}
+		r, n := utf8.DecodeRuneInString(delimiter)
+		if n != len(delimiter) {
+			return 0
+		}
+		return r
+	}
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', bytes.Count(header, []byte(","))
+	for _, c := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(c))); n > count {
+			best, count = c, n
+		}
+	}
+	return best
+}
+
+// mapImportColumns turns the records after the header row into import rows
+// using the col_<field> parameters. Blank rows are skipped.
+func mapImportColumns(records [][]string, q map[string][]string) ([]domain.ImportRow, error) {
+	headerAt := -1
+	for i, rec := range records {
+		if !blankRecord(rec) {
+			headerAt = i
+			break
+		}
+	}
+	if headerAt < 0 {
+		return nil, errBad("file has no header row")
+	}
+	header := records[headerAt]
+
+	cols := map[string]int{}
+	for _, field := range importFields {
+		want := ""
+		if v := q["col_"+field]; len(v) > 0 {
+			want = strings.TrimSpace(v[0])
+		}
+		idx, err := importColumn(header, field, want)
+		if err != nil {
+			return nil, err
+		}
+		if idx >= 0 {
+			cols[field] = idx
+		}
+	}
+	for _, field := range []string{"sku", "name"} {
+		if _, ok := cols[field]; !ok {
+			return nil, errBad("no column for " + field + ", map it with col_" + field)
+		}
+	}
+
+	cell := func(rec []string, field string) string {
+		i, ok := cols[field]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return rec[i]
+	}
+
+	rows := make([]domain.ImportRow, 0, len(records)-headerAt-1)
+	for i := headerAt + 1; i < len(records); i++ {
+		rec := records[i]
+		if blankRecord(rec) {
+			continue
+		}
+		rows = append(rows, domain.ImportRow{
+			Line:     i + 1,
+			SKU:      cell(rec, "sku"),
+			Name:     cell(rec, "name"),
+			Qty:      cell(rec, "qty"),
+			Location: cell(rec, "location"),
+		})
+	}
+	return rows, nil
+}
+
+// importColumn finds the column for field: by the mapped header or 1-based
+// number when want is set, by the field name otherwise. -1 means the file
+// has no such column, which is only allowed for an unmapped field.
+func importColumn(header []string, field, want string) (int, error) {
+	if want != "" {
+		if n, err := strconv.Atoi(want); err == nil {
+			if n < 1 || n > len(header) {
+				return 0, errBad("col_" + field + " is out of range")
+			}
+			return n - 1, nil
+		}
+	}
+	name := want
+	if name == "" {
+		name = field
+	}
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return i, nil
+		}
+	}
+	if want != "" {
+		return 0, errBad("no column " + strconv.Quote(want) + " for " + field)
+	}
+	return -1, nil
+}
+
+func blankRecord(rec []string) bool {
+	for _, c := range rec {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"warehouse/internal/auth"
 	"warehouse/internal/domain"
+	"warehouse/internal/service"
+	"warehouse/internal/ws"
 )
 
 type ctxKey string
@@ -14,8 +17,10 @@ type ctxKey string
 const principalKey ctxKey = "principal"
 
 type Principal struct {
-	Username string
-	Role     domain.Role
+	Username  string
+	Role      domain.Role
+	JTI       string
+	ExpiresAt time.Time
 }
 
 func PrincipalFromContext(ctx context.Context) (Principal, bool) {
@@ -24,7 +29,7 @@ func PrincipalFromContext(ctx context.Context) (Principal, bool) {
 	return p, ok
 }
 
-func RequireAuth(jwtMgr *auth.Manager) func(http.Handler) http.Handler {
+func RequireAuth(jwtMgr *auth.Manager, tokens *service.TokensService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			h := r.Header.Get("Authorization")
@@ -51,9 +56,52 @@ func RequireAuth(jwtMgr *auth.Manager) func(http.Handler) http.Handler {
 				return
 			}
 
-			p := Principal{Username: claims.Username, Role: role}
+			if claims.ID == "" || claims.ExpiresAt == nil {
+				Fail(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+			revoked, err := tokens.IsRevoked(r.Context(), claims.ID)
+			if err != nil {
+				Fail(w, http.StatusInternalServerError, "failed to check token")
+				return
+			}
+			if revoked {
+				Fail(w, http.StatusUnauthorized, "token revoked")
+				return
+			}
+
+			p := Principal{
+				Username:  claims.Username,
+				Role:      role,
+				JTI:       claims.ID,
+				ExpiresAt: claims.ExpiresAt.Time,
+			}
 			ctx := context.WithValue(r.Context(), principalKey, p)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// TokenFromQuery lets clients that can't set headers, such as EventSource
+// and browser WebSockets, pass the access token as ?access_token=. Only
+// feed requests qualify: a GET that asks for text/event-stream or a
+// WebSocket upgrade. Anything else has to send the Authorization header,
+// so the token doesn't end up in links, logs and referrers.
+func TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if t := q.Get("access_token"); t != "" && r.Header.Get("Authorization") == "" && isFeedRequest(r) {
+			r.Header.Set("Authorization", "Bearer "+t)
+			q.Del("access_token")
+			r.URL.RawQuery = q.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isFeedRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	return ws.IsUpgrade(r) || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
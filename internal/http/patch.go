@@ -0,0 +1,180 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"warehouse/internal/domain"
+)
+
+const (
+	mediaMergePatch = "application/merge-patch+json"
+	mediaJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	errUnsupportedPatch = errors.New("unsupported patch media type")
+	errPatchTestFailed  = errors.New("patch test operation failed")
+)
+
+// itemPatch applies a patch document to the current state of an item.
+type itemPatch func(cur domain.Item) (domain.ItemUpdate, error)
+
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+	From  string          `json:"from,omitempty"`
+}
+
+var patchableFields = map[string]bool{"sku": true, "name": true, "qty": true, "location": true}
+
+// decodeItemPatch reads an RFC 7396 merge patch (also accepted as plain
+// application/json) or an RFC 6902 JSON Patch from the request body.
+func decodeItemPatch(r *http.Request) (itemPatch, error) {
+	ct := r.Header.Get("Content-Type")
+	media := mediaMergePatch
+	if ct != "" {
+		var err error
+		if media, _, err = mime.ParseMediaType(ct); err != nil {
+			return nil, errUnsupportedPatch
+		}
+	}
+
+	switch media {
+	case mediaMergePatch, "application/json":
+		var doc map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil || doc == nil {
+			return nil, errBad("merge patch must be a json object")
+		}
+		return mergePatch(doc)
+
+	case mediaJSONPatch:
+		var ops []jsonPatchOp
+		if err := DecodeJSON(r, &ops); err != nil {
+			return nil, errBad("json patch must be an array of operations")
+		}
+		return jsonPatch(ops)
+	}
+
+	return nil, errUnsupportedPatch
+}
+
+// mergePatch applies an RFC 7396 merge patch document.
+func mergePatch(doc map[string]json.RawMessage) (itemPatch, error) {
+	for k := range doc {
+		if !patchableFields[k] {
+			return nil, errBad("unknown field " + k)
+		}
+	}
+	return func(cur domain.Item) (domain.ItemUpdate, error) {
+		state := itemState(cur)
+		for k, raw := range doc {
+			var v any
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return domain.ItemUpdate{}, errBad("invalid value for " + k)
+			}
+			if v == nil {
+				delete(state, k)
+				continue
+			}
+			state[k] = v
+		}
+		return stateToUpdate(state)
+	}, nil
+}
+
+// jsonPatch applies RFC 6902 operations on the item fields.
+func jsonPatch(ops []jsonPatchOp) (itemPatch, error) {
+	for _, op := range ops {
+		switch op.Op {
+		case "add", "replace", "remove", "test":
+		default:
+			return nil, errBad("unsupported patch op " + op.Op)
+		}
+		if !patchableFields[strings.TrimPrefix(op.Path, "/")] {
+			return nil, errBad("unsupported patch path " + op.Path)
+		}
+	}
+	return func(cur domain.Item) (domain.ItemUpdate, error) {
+		state := itemState(cur)
+		for _, op := range ops {
+			field := strings.TrimPrefix(op.Path, "/")
+
+			var v any
+			if op.Op != "remove" {
+				if err := json.Unmarshal(op.Value, &v); err != nil {
+					return domain.ItemUpdate{}, errBad("invalid value for " + op.Path)
+				}
+			}
+
+			switch op.Op {
+			case "test":
+				if !reflect.DeepEqual(state[field], v) {
+					return domain.ItemUpdate{}, errPatchTestFailed
+				}
+			case "remove":
+				if _, ok := state[field]; !ok {
+					return domain.ItemUpdate{}, errBad("nothing to remove at " + op.Path)
+				}
+				delete(state, field)
+			case "replace":
+				if _, ok := state[field]; !ok {
+					return domain.ItemUpdate{}, errBad("nothing to replace at " + op.Path)
+				}
+				state[field] = v
+			case "add":
+				state[field] = v
+			}
+		}
+		return stateToUpdate(state)
+	}, nil
+}
+
+func itemState(it domain.Item) map[string]any {
+	state := map[string]any{
+		"sku":  it.SKU,
+		"name": it.Name,
+		"qty":  float64(it.Qty),
+	}
+	if it.Location != nil {
+		state["location"] = *it.Location
+	}
+	return state
+}
+
+func stateToUpdate(state map[string]any) (domain.ItemUpdate, error) {
+	sku, ok1 := state["sku"].(string)
+	name, ok2 := state["name"].(string)
+	if !ok1 || !ok2 {
+		return domain.ItemUpdate{}, errBad("sku and name must be strings")
+	}
+	sku, name = strings.TrimSpace(sku), strings.TrimSpace(name)
+	if sku == "" || name == "" {
+		return domain.ItemUpdate{}, errBad("sku and name are required")
+	}
+
+	qty, ok := state["qty"].(float64)
+	if !ok || qty != float64(int(qty)) {
+		return domain.ItemUpdate{}, errBad("qty must be an integer")
+	}
+	if qty < 0 {
+		return domain.ItemUpdate{}, errBad("qty must be >= 0")
+	}
+
+	upd := domain.ItemUpdate{SKU: sku, Name: name, Qty: int(qty)}
+	if raw, present := state["location"]; present {
+		loc, ok := raw.(string)
+		if !ok {
+			return domain.ItemUpdate{}, errBad("location must be a string")
+		}
+		if loc = strings.TrimSpace(loc); loc != "" {
+			upd.Location = &loc
+		}
+	}
+	return upd, nil
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"crypto"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -19,7 +20,17 @@ import (
 type Deps struct {
 	Logger *slog.Logger
 	DB     *repo.DB
+	JWT    *auth.Manager
 	Cfg    config.Config
+
+	// AuditSigner signs history checkpoints, nil when not configured.
+	AuditSigner crypto.Signer
+
+	// Events is the running change feed hub.
+	Events *service.EventsService
+
+	// LowStock is the running low-stock evaluator.
+	LowStock *service.LowStockService
 }
 
 func NewRouter(d Deps) http.Handler {
@@ -28,12 +39,13 @@ func NewRouter(d Deps) http.Handler {
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		JSON(w, http.StatusOK, map[string]any{"ok": true})
 	})
 
+	r.Get("/.well-known/jwks.json", JWKSHandler(d.JWT))
+
 	fileServer := http.FileServer(http.Dir("web"))
 	r.Mount("/web", http.StripPrefix("/web", fileServer))
 
@@ -45,40 +57,179 @@ func NewRouter(d Deps) http.Handler {
 		http.Redirect(w, r, "/web/", http.StatusTemporaryRedirect)
 	})
 
-	jwtMgr := auth.NewManager(d.Cfg.JWTSecret)
+	jwtMgr := d.JWT
 
 	itemsRepo := repo.NewItemsRepo(d.DB)
 	historyRepo := repo.NewHistoryRepo(d.DB)
-
-	itemsSvc := service.NewItemsService(d.DB, itemsRepo)
+	usersRepo := repo.NewUsersRepo(d.DB)
+	movementsRepo := repo.NewMovementsRepo(d.DB)
+	stockRepo := repo.NewStockRepo(d.DB)
+	locationsRepo := repo.NewLocationsRepo(d.DB)
+	transfersRepo := repo.NewTransfersRepo(d.DB)
+	tokensRepo := repo.NewTokensRepo(d.DB)
+	webhooksRepo := repo.NewWebhooksRepo(d.DB)
+	lotsRepo := repo.NewLotsRepo(d.DB)
+	serialsRepo := repo.NewSerialsRepo(d.DB)
+	reservationsRepo := repo.NewReservationsRepo(d.DB)
+
+	itemsSvc := service.NewItemsService(d.DB, itemsRepo, historyRepo, movementsRepo, stockRepo, locationsRepo, lotsRepo, serialsRepo, reservationsRepo, webhooksRepo, d.LowStock)
+	movementsSvc := service.NewMovementsService(d.DB, itemsRepo, movementsRepo, stockRepo, locationsRepo, lotsRepo, serialsRepo, reservationsRepo, webhooksRepo, d.LowStock)
+	lotsSvc := service.NewLotsService(lotsRepo)
+	serialsSvc := service.NewSerialsService(d.DB, serialsRepo, itemsRepo, historyRepo)
+	locationsSvc := service.NewLocationsService(locationsRepo, stockRepo)
+	transfersSvc := service.NewTransfersService(d.DB, transfersRepo, itemsRepo, movementsRepo, stockRepo, locationsRepo, webhooksRepo, d.LowStock)
 	historySvc := service.NewHistoryService(historyRepo)
-
-	itemsH := NewItemsHandler(itemsSvc)
-	histH := NewHistoryHandler(historySvc)
+	auditSvc := service.NewAuditService(repo.NewAuditRepo(d.DB), d.AuditSigner)
+	webhooksSvc := service.NewWebhooksService(webhooksRepo)
+	usersSvc := service.NewUsersService(d.DB, usersRepo, tokensRepo)
+	tokensSvc := service.NewTokensService(d.DB, tokensRepo, usersRepo, jwtMgr, d.Cfg.AccessTokenTTL, d.Cfg.RefreshTokenTTL)
+
+	itemsH := NewItemsHandler(itemsSvc, historySvc)
+	histH := NewHistoryHandler(historySvc, d.Logger)
+	auditH := NewAuditHandler(auditSvc)
+	eventsH := NewEventsHandler(d.Events)
+	movH := NewMovementsHandler(movementsSvc)
+	locH := NewLocationsHandler(locationsSvc, itemsSvc)
+	trH := NewTransfersHandler(transfersSvc)
+	usersH := NewUsersHandler(usersSvc, tokensSvc)
+	hooksH := NewWebhooksHandler(webhooksSvc)
+	lotsH := NewLotsHandler(lotsSvc, itemsSvc)
+	serialsH := NewSerialsHandler(serialsSvc, itemsSvc)
+	resH := NewReservationsHandler(itemsSvc, movementsSvc)
+	lowH := NewLowStockHandler(d.LowStock)
 
 	r.Route("/api", func(api chi.Router) {
-		api.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-			JSON(w, http.StatusOK, map[string]any{"pong": true})
-		})
-
-		api.Route("/auth", func(ar chi.Router) {
-			ar.Post("/login", LoginHandler(jwtMgr))
+		// exports, imports, chain verification and the change feed run for
+		// as long as they need, so they sit outside the timeout
+		api.With(TokenFromQuery, RequireAuth(jwtMgr, tokensSvc), RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).
+			Get("/events", eventsH.Stream())
+
+		api.Group(func(ex chi.Router) {
+			ex.Use(RequireAuth(jwtMgr, tokensSvc))
+			ex.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/items/{id}/history.csv", histH.Export())
+			ex.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/items/{id}/history/export", histH.Export())
+			ex.With(RequireRoles(domain.RoleAdmin)).Get("/history/export", histH.ExportAll())
+			ex.With(RequireRoles(domain.RoleAdmin)).Get("/audit/verify", auditH.Verify())
+			ex.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/items/import", itemsH.Import())
 		})
 
-		api.Group(func(pr chi.Router) {
-			pr.Use(RequireAuth(jwtMgr))
+		api.Group(func(api chi.Router) {
+			api.Use(middleware.Timeout(30 * time.Second))
 
-			pr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/me", MeHandler())
+			api.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+				JSON(w, http.StatusOK, map[string]any{"pong": true})
+			})
 
-			// items
-			pr.Route("/items", func(ir chi.Router) {
-				ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/", itemsH.List())
-				ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/", itemsH.Create())
-				ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Put("/{id}", itemsH.Update())
-				ir.With(RequireRoles(domain.RoleAdmin)).Delete("/{id}", itemsH.Delete())
+			api.Route("/auth", func(ar chi.Router) {
+				ar.Post("/login", LoginHandler(usersSvc, tokensSvc))
+				ar.Post("/refresh", RefreshHandler(tokensSvc))
+				ar.With(RequireAuth(jwtMgr, tokensSvc)).Post("/logout", LogoutHandler(tokensSvc))
+			})
 
-				ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/history", histH.ListByItem())
-				ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/history.csv", histH.ExportCSV())
+			api.Group(func(pr chi.Router) {
+				pr.Use(RequireAuth(jwtMgr, tokensSvc))
+
+				pr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/me", MeHandler())
+
+				// items
+				pr.Route("/items", func(ir chi.Router) {
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/", itemsH.List())
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/by-sku/{sku}", itemsH.GetBySKU())
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}", itemsH.Get())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/", itemsH.Create())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/batch", itemsH.Batch())
+					ir.With(RequireRoles(domain.RoleAdmin)).Post("/batch/{batchId}/revert", itemsH.RevertBatch())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Put("/{id}", itemsH.Update())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Patch("/{id}", itemsH.Patch())
+					ir.With(RequireRoles(domain.RoleAdmin)).Delete("/{id}", itemsH.Delete())
+					ir.With(RequireRoles(domain.RoleAdmin)).Post("/{id}/restore", itemsH.Restore())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Put("/{id}/tracking", itemsH.SetTracking())
+
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/history", histH.ListByItem())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/history/{historyId}/revert", itemsH.Revert())
+
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/stock", locH.ItemStock())
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/lots", lotsH.ItemLots())
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/serials", serialsH.ItemSerials())
+
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/reorder-points", lowH.ItemPoints())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Put("/{id}/reorder-points", lowH.SetPoint())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Delete("/{id}/reorder-points", lowH.DeletePoint())
+
+					ir.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/movements", movH.List())
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/movements/receive", movH.Book(domain.MovementReceive))
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/movements/issue", movH.Book(domain.MovementIssue))
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/movements/adjust", movH.Book(domain.MovementAdjust))
+					ir.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/movements/transfer", movH.Book(domain.MovementTransfer))
+				})
+
+				// audit log across all items, deleted ones included
+				pr.With(RequireRoles(domain.RoleAdmin)).Get("/history", histH.List())
+
+				pr.With(RequireRoles(domain.RoleAdmin)).Get("/audit/checkpoints", auditH.Checkpoints())
+
+				// inventory rebuilt from the audit log
+				pr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/inventory/snapshot", histH.Snapshot())
+
+				// locations
+				pr.Route("/locations", func(lr chi.Router) {
+					lr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/", locH.List())
+					lr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/", locH.Create())
+					lr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}/stock", locH.Stock())
+				})
+
+				// lots
+				pr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/lots/expiring", lotsH.Expiring())
+
+				// serial numbers
+				pr.Route("/serials", func(sr chi.Router) {
+					sr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{serial}", serialsH.Get())
+					sr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Put("/{serial}/status", serialsH.SetStatus())
+				})
+
+				// reports
+				pr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/reports/low-stock", lowH.Report())
+
+				// reservations
+				pr.Route("/reservations", func(rr chi.Router) {
+					rr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/", resH.List())
+					rr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}", resH.Get())
+					rr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/", resH.Create())
+					rr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/release", resH.Release())
+					rr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/consume", resH.Consume())
+				})
+
+				// transfers
+				pr.Route("/transfers", func(tr chi.Router) {
+					tr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/", trH.List())
+					tr.With(RequireRoles(domain.RoleViewer, domain.RoleManager, domain.RoleAdmin)).Get("/{id}", trH.Get())
+					tr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/", trH.Create())
+					tr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/dispatch", trH.Dispatch())
+					tr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/receive", trH.Receive())
+					tr.With(RequireRoles(domain.RoleManager, domain.RoleAdmin)).Post("/{id}/cancel", trH.Cancel())
+				})
+
+				// users
+				pr.Route("/users", func(ur chi.Router) {
+					ur.Use(RequireRoles(domain.RoleAdmin))
+					ur.Get("/", usersH.List())
+					ur.Post("/", usersH.Create())
+					ur.Put("/{id}/role", usersH.SetRole())
+					ur.Post("/{id}/disable", usersH.SetDisabled(true))
+					ur.Post("/{id}/enable", usersH.SetDisabled(false))
+					ur.Post("/{id}/sessions/revoke", usersH.RevokeSessions())
+				})
+
+				// outbound webhooks
+				pr.Route("/webhooks", func(wr chi.Router) {
+					wr.Use(RequireRoles(domain.RoleAdmin))
+					wr.Get("/", hooksH.List())
+					wr.Post("/", hooksH.Create())
+					wr.Put("/{id}", hooksH.Update())
+					wr.Delete("/{id}", hooksH.Delete())
+					wr.Get("/{id}/deliveries", hooksH.Deliveries())
+					wr.Post("/{id}/deliveries/{deliveryId}/retry", hooksH.Retry())
+				})
 			})
 		})
 	})
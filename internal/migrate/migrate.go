@@ -0,0 +1,252 @@
+// Package migrate applies the versioned SQL migrations embedded in the
+// binary. Files are named NNNN_name.up.sql / NNNN_name.down.sql and run in
+// version order, each in its own transaction; applied versions are
+// recorded in schema_migrations.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// lockKey serializes migrations of concurrently starting instances.
+const lockKey = `hashtext('schema_migrations')`
+
+var ErrNoDown = errors.New("migration has no down step")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+	// Unknown is set for versions recorded in the database that this
+	// binary has no files for, e.g. after a rollback to an older release.
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	ms, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: ms}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		base, dir, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (dir != "up" && dir != "down") {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+		num, label, _ := strings.Cut(base, "_")
+		v, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", name)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[v]
+		if m == nil {
+			m = &Migration{Version: v, Name: label}
+			byVersion[v] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", v, m.Name, label)
+		}
+		if dir == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Up applies every migration not yet recorded, in version order.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn, applied map[int64]time.Time) error {
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := run(ctx, conn, mg.Up,
+				`insert into schema_migrations(version, name) values ($1, $2)`, mg.Version, mg.Name); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mg.Version, mg.Name, err)
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn, applied map[int64]time.Time) error {
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions[:min(steps, len(versions))] {
+			mg, ok := m.find(v)
+			if !ok {
+				return fmt.Errorf("migration %d is applied but unknown to this binary", v)
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, ErrNoDown)
+			}
+			if err := run(ctx, conn, mg.Down,
+				`delete from schema_migrations where version = $1`, mg.Version); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mg.Version, mg.Name, err)
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known migration with the time it was applied, nil
+// when pending, plus applied versions this binary doesn't know.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := m.locked(ctx, func(_ *pgxpool.Conn, applied map[int64]time.Time) error {
+		for _, mg := range m.migrations {
+			st := Status{Version: mg.Version, Name: mg.Name}
+			if at, ok := applied[mg.Version]; ok {
+				st.AppliedAt = &at
+				delete(applied, mg.Version)
+			}
+			out = append(out, st)
+		}
+		for v, at := range applied {
+			out = append(out, Status{Version: v, AppliedAt: &at, Unknown: true})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+		return nil
+	})
+	return out, err
+}
+
+func (m *Migrator) find(v int64) (Migration, bool) {
+	for _, mg := range m.migrations {
+		if mg.Version == v {
+			return mg, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs fn on a connection holding the migrations advisory lock, so
+// instances started at the same time apply each migration only once.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn, applied map[int64]time.Time) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `select pg_advisory_lock(`+lockKey+`)`); err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), `select pg_advisory_unlock(`+lockKey+`)`)
+	}()
+
+	if _, err := conn.Exec(ctx, `
+create table if not exists schema_migrations (
+  version     bigint primary key,
+  name        text not null,
+  applied_at  timestamptz not null default now()
+)`); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(ctx, `select version, applied_at from schema_migrations`)
+	if err != nil {
+		return err
+	}
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[v] = at
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+// run executes a migration script and its bookkeeping statement in one
+// transaction. The script goes over the simple protocol so it may hold
+// several statements.
+func run(ctx context.Context, conn *pgxpool.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// baseline is db/init.sql as it was before migrations; databases created
+// from it must upgrade with a plain `migrate up`.
+func baseline(t *testing.T) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLoad(t *testing.T) {
+	ms, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range ms {
+		if m.Version != int64(i+1) {
+			t.Fatalf("migration %d_%s: want version %d", m.Version, m.Name, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down step", m.Version, m.Name)
+		}
+	}
+}
+
+// The first migration is the baseline schema itself, without the
+// BEGIN/COMMIT the runner already provides.
+func TestFirstMigrationIsBaseline(t *testing.T) {
+	ms, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(baseline(t), "BEGIN;\n\n", "", 1)
+	want = strings.TrimSuffix(want, "\nCOMMIT;\n")
+	if ms[0].Up != want {
+		t.Fatal("0001 up differs from testdata/init.sql")
+	}
+}
+
+// TestUpgradeFromInitSQL needs a scratch Postgres in TEST_DB_DSN; it works
+// in a schema of its own and drops it afterwards.
+func TestUpgradeFromInitSQL(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if _, err := admin.Exec(ctx, `create schema `+schema); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = admin.Exec(context.Background(), `drop schema `+schema+` cascade`)
+	}()
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, baseline(t)); err != nil {
+		t.Fatalf("init.sql: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `
+insert into items(sku, name, qty, location) values ('OLD-1', 'old', 5, 'A-1');
+update items set qty = 7 where sku = 'OLD-1'`); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(m.migrations) {
+		t.Fatalf("applied %d of %d migrations", len(done), len(m.migrations))
+	}
+
+	var version int64
+	var qty int
+	if err := pool.QueryRow(ctx, `
+update items set qty = 9 where sku = 'OLD-1' returning version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("version after update: got %d, want 2", version)
+	}
+	if err := pool.QueryRow(ctx, `
+select qty from stock_levels s join locations l on l.id = s.location_id where l.code = 'A-1'`).Scan(&qty); err != nil {
+		t.Fatal(err)
+	}
+	if qty != 7 {
+		t.Fatalf("backfilled stock level: got %d, want 7", qty)
+	}
+
+	var action string
+	var hv *int64
+	var seq *int64
+	if err := pool.QueryRow(ctx, `
+select action, version, seq from items_history order by id desc limit 1`).Scan(&action, &hv, &seq); err != nil {
+		t.Fatal(err)
+	}
+	if action != "update" || hv == nil || *hv != 2 || seq == nil {
+		t.Fatalf("history row after upgrade: action %s, version %v, seq %v", action, hv, seq)
+	}
+
+	// rows from before the upgrade are chained too, and the chain holds
+	var unchained, broken int
+	if err := pool.QueryRow(ctx, `
+select count(*) filter (where h.seq is null),
+       count(*) filter (where h.hash is distinct from items_history_hash(h.prev_hash, h)
+                           or h.prev_hash is distinct from p.hash)
+from items_history h
+left join items_history p on p.seq = h.seq - 1`).Scan(&unchained, &broken); err != nil {
+		t.Fatal(err)
+	}
+	if unchained != 0 || broken != 0 {
+		t.Fatalf("history chain after upgrade: %d rows without seq, %d broken links", unchained, broken)
+	}
+
+	again, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("second up applied %d migrations", len(again))
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS items_history;
+DROP TABLE IF EXISTS items;
+
+DROP FUNCTION IF EXISTS audit_items();
+DROP FUNCTION IF EXISTS set_updated_at();
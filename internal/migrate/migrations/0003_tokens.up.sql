@@ -0,0 +1,27 @@
+-- refresh tokens are stored as sha256 hashes; rotated tokens keep their row
+-- (used_at) so that reuse can be detected and the whole family revoked
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id                 BIGSERIAL PRIMARY KEY,
+  token_hash         TEXT NOT NULL UNIQUE,
+  family_id          TEXT NOT NULL,
+  username           TEXT NOT NULL,
+  access_jti         TEXT NOT NULL,
+  access_expires_at  TIMESTAMPTZ NOT NULL,
+  issued_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at         TIMESTAMPTZ NOT NULL,
+  used_at            TIMESTAMPTZ,
+  revoked_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family
+  ON refresh_tokens (family_id);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_username
+  ON refresh_tokens (username);
+
+-- revoked access tokens (by jti), checked on every request
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+  jti         TEXT PRIMARY KEY,
+  expires_at  TIMESTAMPTZ NOT NULL,
+  revoked_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,32 @@
+-- items_history.version stays: history rows are never rewritten
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, actor, actor_role, old_data, new_data)
+    VALUES (NEW.id, 'insert', v_actor, v_role, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, actor, actor_role, old_data, new_data)
+    VALUES (NEW.id, 'update', v_actor, v_role, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, actor, actor_role, old_data, new_data)
+    VALUES (OLD.id, 'delete', v_actor, v_role, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_items_bump_version ON items;
+DROP FUNCTION IF EXISTS bump_version();
+
+ALTER TABLE items DROP COLUMN IF EXISTS version;
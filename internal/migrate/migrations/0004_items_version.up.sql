@@ -0,0 +1,44 @@
+-- optimistic locking: every update bumps the version
+ALTER TABLE items ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE items_history ADD COLUMN IF NOT EXISTS version BIGINT;
+
+CREATE OR REPLACE FUNCTION bump_version()
+RETURNS trigger AS $$
+BEGIN
+  NEW.version = OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_items_bump_version ON items;
+CREATE TRIGGER trg_items_bump_version
+BEFORE UPDATE ON items
+FOR EACH ROW
+EXECUTE FUNCTION bump_version();
+
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, old_data, new_data)
+    VALUES (NEW.id, 'insert', NEW.version, v_actor, v_role, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, old_data, new_data)
+    VALUES (NEW.id, 'update', NEW.version, v_actor, v_role, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
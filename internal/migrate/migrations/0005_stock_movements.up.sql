@@ -0,0 +1,22 @@
+-- Stock ledger: every qty change of an item is a signed movement,
+-- items.qty equals the sum of qty_delta of its movements
+CREATE TABLE IF NOT EXISTS stock_movements (
+  id              BIGSERIAL PRIMARY KEY,
+  item_id         BIGINT NOT NULL,
+  kind            TEXT NOT NULL CHECK (kind IN ('receive','issue','adjust','transfer')),
+  qty_delta       INTEGER NOT NULL,
+  qty_after       INTEGER NOT NULL,
+  location        TEXT,
+  reason          TEXT,
+  reference       TEXT,
+  correlation_id  TEXT,
+  actor           TEXT,
+  actor_role      TEXT,
+  created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_item
+  ON stock_movements (item_id, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_reference
+  ON stock_movements (reference);
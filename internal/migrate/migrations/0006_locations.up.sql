@@ -0,0 +1,38 @@
+-- Locations: warehouse > zone > aisle > bin, codes are globally unique
+CREATE TABLE IF NOT EXISTS locations (
+  id          BIGSERIAL PRIMARY KEY,
+  parent_id   BIGINT REFERENCES locations(id),
+  kind        TEXT NOT NULL CHECK (kind IN ('warehouse','zone','aisle','bin')),
+  code        TEXT NOT NULL UNIQUE,
+  name        TEXT,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_locations_parent
+  ON locations (parent_id);
+
+-- Stock per item and location; location_id NULL is stock not put away yet.
+-- items.qty is the sum over the item, items.location the largest level.
+CREATE TABLE IF NOT EXISTS stock_levels (
+  id           BIGSERIAL PRIMARY KEY,
+  item_id      BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  location_id  BIGINT REFERENCES locations(id),
+  qty          INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_levels_item_location
+  ON stock_levels (item_id, (coalesce(location_id, 0)));
+
+CREATE INDEX IF NOT EXISTS idx_stock_levels_location
+  ON stock_levels (location_id);
+
+-- backfill from the old single-location model
+INSERT INTO locations(kind, code)
+SELECT DISTINCT 'bin', location FROM items WHERE location IS NOT NULL
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO stock_levels(item_id, location_id, qty)
+SELECT i.id, l.id, i.qty
+FROM items i LEFT JOIN locations l ON l.code = i.location
+WHERE i.qty <> 0 OR i.location IS NOT NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,32 @@
+DROP TABLE IF EXISTS transfer_lines;
+DROP TABLE IF EXISTS transfers;
+
+-- items_history.correlation_id stays: history rows are never rewritten
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, old_data, new_data)
+    VALUES (NEW.id, 'insert', NEW.version, v_actor, v_role, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, old_data, new_data)
+    VALUES (NEW.id, 'update', NEW.version, v_actor, v_role, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_items_history_correlation;
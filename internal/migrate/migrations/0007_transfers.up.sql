@@ -0,0 +1,67 @@
+-- history rows written by one request share its correlation id
+ALTER TABLE items_history ADD COLUMN IF NOT EXISTS correlation_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_items_history_correlation
+  ON items_history (correlation_id);
+
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+  v_corr  TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+  v_corr  := NULLIF(current_setting('app.correlation_id', true), '');
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (NEW.id, 'insert', NEW.version, v_actor, v_role, v_corr, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (NEW.id, 'update', NEW.version, v_actor, v_role, v_corr, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, v_corr, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Transfer documents: draft -> in_transit -> received (or cancelled);
+-- dispatched goods sit in the IN-TRANSIT bin until received
+CREATE TABLE IF NOT EXISTS transfers (
+  id                BIGSERIAL PRIMARY KEY,
+  status            TEXT NOT NULL CHECK (status IN ('draft','in_transit','received','cancelled')),
+  from_location_id  BIGINT NOT NULL REFERENCES locations(id),
+  to_location_id    BIGINT NOT NULL REFERENCES locations(id),
+  reference         TEXT,
+  correlation_id    TEXT NOT NULL,
+  created_by        TEXT,
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+  dispatched_at     TIMESTAMPTZ,
+  received_at       TIMESTAMPTZ,
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS trg_transfers_set_updated_at ON transfers;
+CREATE TRIGGER trg_transfers_set_updated_at
+BEFORE UPDATE ON transfers
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX IF NOT EXISTS idx_transfers_status
+  ON transfers (status);
+
+CREATE TABLE IF NOT EXISTS transfer_lines (
+  id           BIGSERIAL PRIMARY KEY,
+  transfer_id  BIGINT NOT NULL REFERENCES transfers(id) ON DELETE CASCADE,
+  item_id      BIGINT NOT NULL,
+  qty          INTEGER NOT NULL CHECK (qty > 0),
+  UNIQUE (transfer_id, item_id)
+);
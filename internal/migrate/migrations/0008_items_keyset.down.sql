@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_items_updated_at_id;
+DROP INDEX IF EXISTS idx_items_qty_id;
+DROP INDEX IF EXISTS idx_items_name_id;
@@ -0,0 +1,4 @@
+-- keyset pagination of the items list (sku is covered by its unique index)
+CREATE INDEX IF NOT EXISTS idx_items_name_id ON items (name, id);
+CREATE INDEX IF NOT EXISTS idx_items_qty_id ON items (qty, id);
+CREATE INDEX IF NOT EXISTS idx_items_updated_at_id ON items (updated_at, id);
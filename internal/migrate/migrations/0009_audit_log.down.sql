@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_items_history_old_sku;
+DROP INDEX IF EXISTS idx_items_history_new_sku;
+DROP INDEX IF EXISTS idx_items_history_time_id;
@@ -0,0 +1,9 @@
+-- global audit log: keyset pagination and lookup of deleted items by sku
+CREATE INDEX IF NOT EXISTS idx_items_history_time_id
+  ON items_history (changed_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_items_history_new_sku
+  ON items_history ((new_data->>'sku'));
+
+CREATE INDEX IF NOT EXISTS idx_items_history_old_sku
+  ON items_history ((old_data->>'sku'));
@@ -0,0 +1,30 @@
+-- revert rows stay in the history, and so do source_history_id and the
+-- wider action check
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+  v_corr  TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+  v_corr  := NULLIF(current_setting('app.correlation_id', true), '');
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (NEW.id, 'insert', NEW.version, v_actor, v_role, v_corr, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (NEW.id, 'update', NEW.version, v_actor, v_role, v_corr, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, v_corr, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+-- seq, prev_hash and hash stay on the rows already chained
+DROP TABLE IF EXISTS history_checkpoints;
+
+DROP TRIGGER IF EXISTS trg_items_history_append_only ON items_history;
+DROP FUNCTION IF EXISTS forbid_history_change();
+
+DROP TRIGGER IF EXISTS trg_items_history_chain ON items_history;
+DROP FUNCTION IF EXISTS chain_items_history();
+DROP FUNCTION IF EXISTS items_history_hash(BYTEA, items_history);
@@ -0,0 +1,94 @@
+-- hash chain, filled by chain_items_history()
+ALTER TABLE items_history ADD COLUMN IF NOT EXISTS seq BIGINT UNIQUE;
+ALTER TABLE items_history ADD COLUMN IF NOT EXISTS prev_hash BYTEA;
+ALTER TABLE items_history ADD COLUMN IF NOT EXISTS hash BYTEA;
+
+-- Tamper evidence: every history row stores sha256(prev_hash || row) and a
+-- gap-free seq, so editing, deleting or reordering rows breaks the chain.
+-- Writers are serialized by an advisory lock held until commit.
+CREATE OR REPLACE FUNCTION items_history_hash(prev BYTEA, h items_history)
+RETURNS BYTEA AS $$
+  SELECT sha256(coalesce(prev, ''::bytea) || convert_to(jsonb_build_array(
+    h.seq, h.id, h.item_id, h.action, h.version, h.actor, h.actor_role,
+    h.correlation_id, h.source_history_id,
+    (extract(epoch FROM h.changed_at) * 1000000)::bigint,
+    h.old_data, h.new_data
+  )::text, 'UTF8'));
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION chain_items_history()
+RETURNS trigger AS $$
+DECLARE
+  v_seq  BIGINT;
+  v_prev BYTEA;
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('items_history_chain'));
+
+  SELECT seq, hash INTO v_seq, v_prev
+  FROM items_history
+  WHERE seq IS NOT NULL
+  ORDER BY seq DESC
+  LIMIT 1;
+
+  NEW.seq := coalesce(v_seq, 0) + 1;
+  NEW.prev_hash := v_prev;
+  NEW.hash := items_history_hash(v_prev, NEW);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_items_history_chain ON items_history;
+CREATE TRIGGER trg_items_history_chain
+BEFORE INSERT ON items_history
+FOR EACH ROW
+EXECUTE FUNCTION chain_items_history();
+
+-- rows written before the chain existed join it once, in id order, after
+-- whatever is chained already; this has to happen before the table becomes
+-- append-only
+DROP TRIGGER IF EXISTS trg_items_history_append_only ON items_history;
+
+DO $$
+DECLARE
+  v_seq  BIGINT;
+  v_prev BYTEA;
+  r      items_history;
+BEGIN
+  SELECT seq, hash INTO v_seq, v_prev
+  FROM items_history
+  WHERE seq IS NOT NULL
+  ORDER BY seq DESC
+  LIMIT 1;
+  v_seq := coalesce(v_seq, 0);
+
+  FOR r IN SELECT * FROM items_history WHERE seq IS NULL ORDER BY id LOOP
+    v_seq := v_seq + 1;
+    r.seq := v_seq;
+    r.prev_hash := v_prev;
+    r.hash := items_history_hash(v_prev, r);
+    UPDATE items_history SET seq = r.seq, prev_hash = r.prev_hash, hash = r.hash WHERE id = r.id;
+    v_prev := r.hash;
+  END LOOP;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION forbid_history_change()
+RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'items_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_items_history_append_only
+BEFORE UPDATE OR DELETE ON items_history
+FOR EACH ROW
+EXECUTE FUNCTION forbid_history_change();
+
+-- signed daily checkpoints of the chain head for offline verification
+CREATE TABLE IF NOT EXISTS history_checkpoints (
+  day         DATE PRIMARY KEY,
+  last_seq    BIGINT NOT NULL,
+  last_hash   BYTEA NOT NULL,
+  signature   BYTEA NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trg_items_history_notify ON items_history;
+DROP FUNCTION IF EXISTS notify_items_history();
@@ -0,0 +1,14 @@
+-- live change feed: listeners get the id of every committed history row
+CREATE OR REPLACE FUNCTION notify_items_history()
+RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('items_history', NEW.id::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_items_history_notify ON items_history;
+CREATE TRIGGER trg_items_history_notify
+AFTER INSERT ON items_history
+FOR EACH ROW
+EXECUTE FUNCTION notify_items_history();
@@ -0,0 +1,45 @@
+-- Outbound webhooks: subscriptions, an outbox written in the same
+-- transaction as the item change and one delivery row per subscriber
+CREATE TABLE IF NOT EXISTS webhooks (
+  id           BIGSERIAL PRIMARY KEY,
+  url          TEXT NOT NULL,
+  secret       TEXT NOT NULL,
+  event_types  TEXT[] NOT NULL,
+  active       BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS trg_webhooks_set_updated_at ON webhooks;
+CREATE TRIGGER trg_webhooks_set_updated_at
+BEFORE UPDATE ON webhooks
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+  id          BIGSERIAL PRIMARY KEY,
+  event_id    TEXT NOT NULL UNIQUE,
+  event_type  TEXT NOT NULL,
+  item_id     BIGINT,
+  payload     JSONB NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id               BIGSERIAL PRIMARY KEY,
+  webhook_id       BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  outbox_id        BIGINT NOT NULL REFERENCES webhook_outbox(id) ON DELETE CASCADE,
+  status           TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','delivered','dead')),
+  attempts         INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_status      INTEGER,
+  last_error       TEXT,
+  delivered_at     TIMESTAMPTZ,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+  ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook
+  ON webhook_deliveries (webhook_id, id DESC);
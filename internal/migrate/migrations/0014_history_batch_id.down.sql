@@ -0,0 +1,36 @@
+-- items_history is append-only, so batch_id and its place in the hash stay:
+-- dropping either would break the chain for rows written with a batch id.
+-- Only new rows stop getting one.
+
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+  v_corr  TEXT := NULL;
+  v_src   BIGINT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+  v_corr  := NULLIF(current_setting('app.correlation_id', true), '');
+  v_src   := NULLIF(current_setting('app.revert_of', true), '')::bigint;
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'insert' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'update' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, v_corr, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_items_history_batch;
@@ -0,0 +1,51 @@
+-- batch operations tag every history row they produce with a shared id
+ALTER TABLE items_history ADD COLUMN IF NOT EXISTS batch_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_items_history_batch
+  ON items_history (batch_id) WHERE batch_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+  v_corr  TEXT := NULL;
+  v_src   BIGINT := NULL;
+  v_batch TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+  v_corr  := NULLIF(current_setting('app.correlation_id', true), '');
+  -- set by a revert: the history row whose snapshot is being restored
+  v_src   := NULLIF(current_setting('app.revert_of', true), '')::bigint;
+  v_batch := NULLIF(current_setting('app.batch_id', true), '');
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'insert' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'update' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, batch_id, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, v_corr, v_batch, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- batch_id is hashed only when set, so rows written before it existed keep
+-- their hashes
+CREATE OR REPLACE FUNCTION items_history_hash(prev BYTEA, h items_history)
+RETURNS BYTEA AS $$
+  SELECT sha256(coalesce(prev, ''::bytea) || convert_to((jsonb_build_array(
+    h.seq, h.id, h.item_id, h.action, h.version, h.actor, h.actor_role,
+    h.correlation_id, h.source_history_id,
+    (extract(epoch FROM h.changed_at) * 1000000)::bigint,
+    h.old_data, h.new_data
+  ) || CASE WHEN h.batch_id IS NULL THEN '[]'::jsonb ELSE jsonb_build_array(h.batch_id) END)::text, 'UTF8'));
+$$ LANGUAGE sql IMMUTABLE;
@@ -0,0 +1,45 @@
+-- items in the trash can't be kept without deleted_at, so they are purged
+-- (and audited as such) before the column goes. restore and purge rows stay
+-- in the append-only history, and so does the wider action check.
+DELETE FROM items WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+  v_corr  TEXT := NULL;
+  v_src   BIGINT := NULL;
+  v_batch TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+  v_corr  := NULLIF(current_setting('app.correlation_id', true), '');
+  -- set by a revert: the history row whose snapshot is being restored
+  v_src   := NULLIF(current_setting('app.revert_of', true), '')::bigint;
+  v_batch := NULLIF(current_setting('app.batch_id', true), '');
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'insert' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'update' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, batch_id, old_data, new_data)
+    VALUES (OLD.id, 'delete', OLD.version, v_actor, v_role, v_corr, v_batch, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_items_deleted_at;
+DROP INDEX IF EXISTS idx_items_sku_live;
+ALTER TABLE items ADD CONSTRAINT items_sku_key UNIQUE (sku);
+
+ALTER TABLE items DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE items DROP COLUMN IF EXISTS deleted_at;
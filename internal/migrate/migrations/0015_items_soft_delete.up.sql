@@ -0,0 +1,62 @@
+-- soft delete: a deleted item keeps its row, id and stock until the purge
+-- removes it for good
+ALTER TABLE items ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+
+-- the sku of a deleted item may be taken by a new one
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_sku_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_items_sku_live
+  ON items (sku) WHERE deleted_at IS NULL;
+
+-- trash listing and purge
+CREATE INDEX IF NOT EXISTS idx_items_deleted_at
+  ON items (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE items_history DROP CONSTRAINT IF EXISTS items_history_action_check;
+ALTER TABLE items_history ADD CONSTRAINT items_history_action_check
+  CHECK (action IN ('insert','update','delete','revert','restore','purge'));
+
+-- setting deleted_at is audited as a delete and clearing it as a restore,
+-- with the same snapshots a hard delete and an insert would have; removing
+-- a soft-deleted row is a purge
+CREATE OR REPLACE FUNCTION audit_items()
+RETURNS trigger AS $$
+DECLARE
+  v_actor TEXT := NULL;
+  v_role  TEXT := NULL;
+  v_corr  TEXT := NULL;
+  v_src   BIGINT := NULL;
+  v_batch TEXT := NULL;
+BEGIN
+  v_actor := current_setting('app.user', true);
+  v_role  := current_setting('app.role', true);
+  v_corr  := NULLIF(current_setting('app.correlation_id', true), '');
+  -- set by a revert: the history row whose snapshot is being restored
+  v_src   := NULLIF(current_setting('app.revert_of', true), '')::bigint;
+  v_batch := NULLIF(current_setting('app.batch_id', true), '');
+
+  IF (TG_OP = 'INSERT') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+    VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'insert' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, NULL, to_jsonb(NEW));
+    RETURN NEW;
+  ELSIF (TG_OP = 'UPDATE') THEN
+    IF (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL) THEN
+      INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, batch_id, old_data, new_data)
+      VALUES (NEW.id, 'delete', NEW.version, v_actor, v_role, v_corr, v_batch, to_jsonb(OLD), NULL);
+    ELSIF (OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL) THEN
+      INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+      VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'restore' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, NULL, to_jsonb(NEW));
+    ELSE
+      INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, old_data, new_data)
+      VALUES (NEW.id, CASE WHEN v_src IS NULL THEN 'update' ELSE 'revert' END, NEW.version, v_actor, v_role, v_corr, v_src, v_batch, to_jsonb(OLD), to_jsonb(NEW));
+    END IF;
+    RETURN NEW;
+  ELSIF (TG_OP = 'DELETE') THEN
+    INSERT INTO items_history(item_id, action, version, actor, actor_role, correlation_id, batch_id, old_data, new_data)
+    VALUES (OLD.id, CASE WHEN OLD.deleted_at IS NULL THEN 'delete' ELSE 'purge' END, OLD.version, v_actor, v_role, v_corr, v_batch, to_jsonb(OLD), NULL);
+    RETURN OLD;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
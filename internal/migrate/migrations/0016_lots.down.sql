@@ -0,0 +1,9 @@
+-- lot rows stay in the append-only history, and so does the wider action
+-- check
+DROP TRIGGER IF EXISTS trg_lots_audit ON lots;
+DROP FUNCTION IF EXISTS audit_lots();
+
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS lot_number;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS lot_id;
+
+DROP TABLE IF EXISTS lots;
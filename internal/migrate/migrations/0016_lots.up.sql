@@ -0,0 +1,71 @@
+-- lots: stock of an item split by lot number with manufacture and expiry
+-- dates; the sum over the lots is the lot-tracked part of items.qty
+CREATE TABLE IF NOT EXISTS lots (
+  id               BIGSERIAL PRIMARY KEY,
+  item_id          BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  lot_number       TEXT NOT NULL,
+  manufactured_on  DATE,
+  expires_on       DATE,
+  supplier         TEXT,
+  qty              INTEGER NOT NULL DEFAULT 0 CHECK (qty >= 0),
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (item_id, lot_number),
+  CHECK (expires_on IS NULL OR manufactured_on IS NULL OR expires_on >= manufactured_on)
+);
+
+-- FEFO allocation and the expiry report only look at lots with stock
+CREATE INDEX IF NOT EXISTS idx_lots_fefo
+  ON lots (item_id, expires_on, id) WHERE qty > 0;
+
+CREATE INDEX IF NOT EXISTS idx_lots_expires_on
+  ON lots (expires_on) WHERE qty > 0;
+
+DROP TRIGGER IF EXISTS trg_lots_set_updated_at ON lots;
+CREATE TRIGGER trg_lots_set_updated_at
+BEFORE UPDATE ON lots
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS lot_id BIGINT;
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS lot_number TEXT;
+
+-- lot changes go into the item's history as action 'lot' with lot
+-- snapshots, so they are part of the hash chain
+ALTER TABLE items_history DROP CONSTRAINT IF EXISTS items_history_action_check;
+ALTER TABLE items_history ADD CONSTRAINT items_history_action_check
+  CHECK (action IN ('insert','update','delete','revert','restore','purge','lot'));
+
+CREATE OR REPLACE FUNCTION audit_lots()
+RETURNS trigger AS $$
+DECLARE
+  v_old JSONB := NULL;
+  v_new JSONB := NULL;
+  v_item BIGINT;
+BEGIN
+  IF (TG_OP <> 'INSERT') THEN
+    v_old := to_jsonb(OLD);
+    v_item := OLD.item_id;
+  END IF;
+  IF (TG_OP <> 'DELETE') THEN
+    v_new := to_jsonb(NEW);
+    v_item := NEW.item_id;
+  END IF;
+
+  INSERT INTO items_history(item_id, action, actor, actor_role, correlation_id, batch_id, old_data, new_data)
+  VALUES (v_item, 'lot',
+    current_setting('app.user', true),
+    current_setting('app.role', true),
+    NULLIF(current_setting('app.correlation_id', true), ''),
+    NULLIF(current_setting('app.batch_id', true), ''),
+    v_old, v_new);
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_lots_audit ON lots;
+CREATE TRIGGER trg_lots_audit
+AFTER INSERT OR UPDATE OR DELETE ON lots
+FOR EACH ROW
+EXECUTE FUNCTION audit_lots();
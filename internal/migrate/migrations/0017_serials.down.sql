@@ -0,0 +1,14 @@
+-- serial rows stay in the append-only history, and so does the wider action
+-- check
+DROP TRIGGER IF EXISTS trg_serials_serial_qty ON serials;
+DROP TRIGGER IF EXISTS trg_items_serial_qty ON items;
+DROP FUNCTION IF EXISTS check_serial_qty();
+
+DROP TRIGGER IF EXISTS trg_serials_audit ON serials;
+DROP FUNCTION IF EXISTS audit_serials();
+
+DROP INDEX IF EXISTS idx_items_history_serial;
+
+DROP TABLE IF EXISTS serials;
+
+ALTER TABLE items DROP COLUMN IF EXISTS tracking;
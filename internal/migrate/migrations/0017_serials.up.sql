@@ -0,0 +1,118 @@
+-- tracking mode of an item: plain qty, lots or serial numbers
+ALTER TABLE items ADD COLUMN IF NOT EXISTS tracking TEXT NOT NULL DEFAULT 'none'
+  CHECK (tracking IN ('none','lot','serial'));
+
+-- serial numbers are unique across all items; in_stock, reserved and
+-- returned units are on hand, shipped and scrapped ones are gone
+CREATE TABLE IF NOT EXISTS serials (
+  id           BIGSERIAL PRIMARY KEY,
+  item_id      BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  serial       TEXT NOT NULL UNIQUE,
+  status       TEXT NOT NULL CHECK (status IN ('in_stock','reserved','shipped','returned','scrapped')),
+  location_id  BIGINT REFERENCES locations(id),
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_serials_item_status
+  ON serials (item_id, status);
+
+DROP TRIGGER IF EXISTS trg_serials_set_updated_at ON serials;
+CREATE TRIGGER trg_serials_set_updated_at
+BEFORE UPDATE ON serials
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE items_history DROP CONSTRAINT IF EXISTS items_history_action_check;
+ALTER TABLE items_history ADD CONSTRAINT items_history_action_check
+  CHECK (action IN ('insert','update','delete','revert','restore','purge','lot','serial'));
+
+-- lifecycle lookup by serial number
+CREATE INDEX IF NOT EXISTS idx_items_history_serial
+  ON items_history ((coalesce(new_data, old_data)->>'serial')) WHERE action = 'serial';
+
+-- serial changes go into the item's history as action 'serial'
+CREATE OR REPLACE FUNCTION audit_serials()
+RETURNS trigger AS $$
+DECLARE
+  v_old JSONB := NULL;
+  v_new JSONB := NULL;
+  v_item BIGINT;
+BEGIN
+  IF (TG_OP <> 'INSERT') THEN
+    v_old := to_jsonb(OLD);
+    v_item := OLD.item_id;
+  END IF;
+  IF (TG_OP <> 'DELETE') THEN
+    v_new := to_jsonb(NEW);
+    v_item := NEW.item_id;
+  END IF;
+
+  INSERT INTO items_history(item_id, action, actor, actor_role, correlation_id, batch_id, old_data, new_data)
+  VALUES (v_item, 'serial',
+    current_setting('app.user', true),
+    current_setting('app.role', true),
+    NULLIF(current_setting('app.correlation_id', true), ''),
+    NULLIF(current_setting('app.batch_id', true), ''),
+    v_old, v_new);
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_serials_audit ON serials;
+CREATE TRIGGER trg_serials_audit
+AFTER INSERT OR UPDATE OR DELETE ON serials
+FOR EACH ROW
+EXECUTE FUNCTION audit_serials();
+
+-- the qty of a serialized item is the number of its serials on hand; checked
+-- at commit so that the item and its serials can be written in any order
+CREATE OR REPLACE FUNCTION check_serial_qty()
+RETURNS trigger AS $$
+DECLARE
+  v_item     BIGINT;
+  v_qty      INTEGER;
+  v_tracking TEXT;
+  v_count    INTEGER;
+BEGIN
+  IF (TG_TABLE_NAME = 'items') THEN
+    v_item := NEW.id;
+  ELSIF (TG_OP = 'DELETE') THEN
+    v_item := OLD.item_id;
+  ELSE
+    v_item := NEW.item_id;
+  END IF;
+
+  SELECT qty, tracking INTO v_qty, v_tracking
+  FROM items
+  WHERE id = v_item AND deleted_at IS NULL;
+  IF NOT FOUND OR v_tracking <> 'serial' THEN
+    RETURN NULL;
+  END IF;
+
+  SELECT count(*) INTO v_count
+  FROM serials
+  WHERE item_id = v_item AND status IN ('in_stock','reserved','returned');
+
+  IF v_count <> v_qty THEN
+    RAISE EXCEPTION 'serialized item % has qty % but % serials on hand', v_item, v_qty, v_count
+      USING ERRCODE = 'check_violation', CONSTRAINT = 'items_serial_qty';
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_items_serial_qty ON items;
+CREATE CONSTRAINT TRIGGER trg_items_serial_qty
+AFTER INSERT OR UPDATE ON items
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION check_serial_qty();
+
+DROP TRIGGER IF EXISTS trg_serials_serial_qty ON serials;
+CREATE CONSTRAINT TRIGGER trg_serials_serial_qty
+AFTER INSERT OR UPDATE OR DELETE ON serials
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION check_serial_qty();
@@ -0,0 +1,21 @@
+-- stock promised to an order; an active reservation past expires_at no
+-- longer counts and is reported as expired
+CREATE TABLE IF NOT EXISTS reservations (
+  id          BIGSERIAL PRIMARY KEY,
+  item_id     BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  qty         INTEGER NOT NULL CHECK (qty > 0),
+  owner       TEXT NOT NULL,
+  reference   TEXT,
+  status      TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','released','consumed')),
+  expires_at  TIMESTAMPTZ,
+  created_by  TEXT NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  closed_by   TEXT,
+  closed_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservations_item_active
+  ON reservations (item_id) WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS idx_reservations_owner
+  ON reservations (owner, created_at);
@@ -0,0 +1,24 @@
+-- replenishment settings of an item, for its whole stock (location_id NULL)
+-- or for one location; alerted_at is set while an alert is outstanding
+CREATE TABLE IF NOT EXISTS reorder_points (
+  id             BIGSERIAL PRIMARY KEY,
+  item_id        BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  location_id    BIGINT REFERENCES locations(id) ON DELETE CASCADE,
+  min_qty        INTEGER NOT NULL CHECK (min_qty >= 0),
+  reorder_point  INTEGER NOT NULL,
+  max_qty        INTEGER,
+  alerted_at     TIMESTAMPTZ,
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (reorder_point >= min_qty),
+  CHECK (max_qty IS NULL OR max_qty >= reorder_point)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_reorder_points_item_location
+  ON reorder_points (item_id, (coalesce(location_id, 0)));
+
+DROP TRIGGER IF EXISTS trg_reorder_points_set_updated_at ON reorder_points;
+CREATE TRIGGER trg_reorder_points_set_updated_at
+BEFORE UPDATE ON reorder_points
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_serials_reservation;
+
+ALTER TABLE serials DROP COLUMN IF EXISTS reservation_id;
@@ -0,0 +1,8 @@
+-- a reserved unit belongs to one reservation; once that reservation is no
+-- longer active (released, consumed or expired) the unit reads as in_stock,
+-- which also covers units reserved before this column existed
+ALTER TABLE serials ADD COLUMN IF NOT EXISTS reservation_id BIGINT
+  REFERENCES reservations(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_serials_reservation
+  ON serials (reservation_id) WHERE reservation_id IS NOT NULL;
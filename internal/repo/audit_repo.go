@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type AuditRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewAuditRepo(db *DB) *AuditRepo {
+	return &AuditRepo{pool: db.Pool}
+}
+
+// WalkChain streams the history chain in seq order. Rows that were slipped
+// in past the chaining trigger have no seq and come first.
+func (r *AuditRepo) WalkChain(ctx context.Context, fn func(domain.ChainLink) error) error {
+	rows, err := r.pool.Query(ctx, `
+select seq, id, prev_hash, hash, items_history_hash(prev_hash, h)
+from items_history h
+order by seq nulls first, id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l domain.ChainLink
+		if err := rows.Scan(&l.Seq, &l.ID, &l.PrevHash, &l.Hash, &l.Expected); err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// HeadBefore returns the last link of the chain written before the given
+// time. ErrNotFound means there is none.
+func (r *AuditRepo) HeadBefore(ctx context.Context, before time.Time) (int64, []byte, error) {
+	var seq int64
+	var hash []byte
+	err := r.pool.QueryRow(ctx, `
+select seq, hash from items_history
+where seq is not null and changed_at < $1
+order by seq desc
+limit 1`, before).Scan(&seq, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, ErrNotFound
+	}
+	return seq, hash, err
+}
+
+// InsertCheckpoint stores the checkpoint unless the day already has one.
+func (r *AuditRepo) InsertCheckpoint(ctx context.Context, day time.Time, seq int64, hash, signature []byte) (bool, error) {
+	ct, err := r.pool.Exec(ctx, `
+insert into history_checkpoints(day, last_seq, last_hash, signature)
+values ($1,$2,$3,$4)
+on conflict (day) do nothing`, day, seq, hash, signature)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
+// ListCheckpoints returns stored checkpoints oldest first, with the hash in
+// hex and the signature in base64. Message is left to the caller.
+func (r *AuditRepo) ListCheckpoints(ctx context.Context) ([]domain.Checkpoint, error) {
+	rows, err := r.pool.Query(ctx, `
+select day, last_seq, last_hash, signature, created_at
+from history_checkpoints
+order by day`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Checkpoint, 0)
+	for rows.Next() {
+		var c domain.Checkpoint
+		var day time.Time
+		var hash, sig []byte
+		if err := rows.Scan(&day, &c.LastSeq, &hash, &sig, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.Day = day.Format(time.DateOnly)
+		c.LastHash = hex.EncodeToString(hash)
+		c.Signature = base64.StdEncoding.EncodeToString(sig)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
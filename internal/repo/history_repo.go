@@ -3,9 +3,12 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"warehouse/internal/domain"
@@ -19,15 +22,86 @@ func NewHistoryRepo(db *DB) *HistoryRepo {
 	return &HistoryRepo{pool: db.Pool}
 }
 
+func (r *HistoryRepo) Get(ctx context.Context, id int64) (domain.HistoryEntry, error) {
+	e, err := scanHistory(r.pool.QueryRow(ctx, `select `+historyColumns+` from items_history where id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.HistoryEntry{}, ErrNotFound
+	}
+	return e, err
+}
+
+// GetInTx is Get inside the caller's transaction.
+func (r *HistoryRepo) GetInTx(ctx context.Context, tx pgx.Tx, id int64) (domain.HistoryEntry, error) {
+	e, err := scanHistory(tx.QueryRow(ctx, `select `+historyColumns+` from items_history where id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.HistoryEntry{}, ErrNotFound
+	}
+	return e, err
+}
+
 func (r *HistoryRepo) ListByItem(ctx context.Context, itemID int64, f domain.HistoryFilter) ([]domain.HistoryEntry, error) {
+	f.ItemID = &itemID
+	q, args, _ := historyQuery(f)
+	q += ` order by changed_at desc, id desc`
+
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectHistory(rows)
+}
+
+// List pages through the history of all items, deleted ones included,
+// newest first using keyset pagination on (changed_at, id).
+func (r *HistoryRepo) List(ctx context.Context, f domain.HistoryFilter, after *domain.HistoryCursor, limit int) (domain.HistoryPage, error) {
+	q, args, idx := historyQuery(f)
+	if after != nil {
+		q += ` and (changed_at, id) < ($` + strconv.Itoa(idx) + `, $` + strconv.Itoa(idx+1) + `)`
+		args = append(args, after.ChangedAt, after.ID)
+		idx += 2
+	}
+	q += ` order by changed_at desc, id desc limit $` + strconv.Itoa(idx)
+	args = append(args, limit+1)
+
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return domain.HistoryPage{}, err
+	}
+	entries, err := collectHistory(rows)
+	if err != nil {
+		return domain.HistoryPage{}, err
+	}
+
+	page := domain.HistoryPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		last := page.Entries[limit-1]
+		page.Next = &domain.HistoryCursor{ChangedAt: last.ChangedAt, ID: last.ID}
+	}
+	return page, nil
+}
+
+const historyColumns = `id, seq, item_id, action, version, actor, actor_role, correlation_id, source_history_id, batch_id, changed_at, old_data, new_data`
+
+func historyQuery(f domain.HistoryFilter) (string, []any, int) {
 	q := `
-select id, item_id, action, actor, actor_role, changed_at, old_data, new_data
+select ` + historyColumns + `
 from items_history
-where item_id = $1
+where true
 `
-	args := []any{itemID}
-	idx := 2
+	args := []any{}
+	idx := 1
 
+	if f.ItemID != nil {
+		q += ` and item_id = $` + strconv.Itoa(idx)
+		args = append(args, *f.ItemID)
+		idx++
+	}
+	if f.SKU != nil && strings.TrimSpace(*f.SKU) != "" {
+		q += ` and (new_data->>'sku' = $` + strconv.Itoa(idx) + ` or old_data->>'sku' = $` + strconv.Itoa(idx) + `)`
+		args = append(args, strings.TrimSpace(*f.SKU))
+		idx++
+	}
 	if f.From != nil {
 		q += ` and changed_at >= $` + strconv.Itoa(idx)
 		args = append(args, *f.From)
@@ -48,37 +122,197 @@ where item_id = $1
 		args = append(args, strings.TrimSpace(*f.Action))
 		idx++
 	}
+	if f.BatchID != nil && strings.TrimSpace(*f.BatchID) != "" {
+		q += ` and batch_id = $` + strconv.Itoa(idx)
+		args = append(args, strings.TrimSpace(*f.BatchID))
+		idx++
+	}
+
+	return q, args, idx
+}
 
+// Stream runs the history query and hands entries to fn one by one as they
+// are read from the connection, newest first, without loading them all.
+func (r *HistoryRepo) Stream(ctx context.Context, f domain.HistoryFilter, fn func(domain.HistoryEntry) error) error {
+	q, args, _ := historyQuery(f)
 	q += ` order by changed_at desc, id desc`
 
 	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanHistory(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListSince returns up to limit entries with seq greater than afterSeq in
+// chain order, for clients catching up on the change feed. seq is handed out
+// in commit order, unlike id, so nothing committed late is skipped.
+func (r *HistoryRepo) ListSince(ctx context.Context, afterSeq int64, limit int) ([]domain.HistoryEntry, error) {
+	rows, err := r.pool.Query(ctx, `select `+historyColumns+` from items_history where seq > $1 order by seq limit $2`, afterSeq, limit)
 	if err != nil {
 		return nil, err
 	}
+	return collectHistory(rows)
+}
+
+// Listen holds a connection LISTENing on the items_history channel and
+// calls fn with the id of every new entry until ctx is done or the
+// connection fails.
+func (r *HistoryRepo) Listen(ctx context.Context, fn func(id int64)) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// don't hand a listening connection back to the pool
+		_, _ = conn.Exec(context.Background(), `unlisten *`)
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, `listen items_history`); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			continue
+		}
+		fn(id)
+	}
+}
+
+func collectHistory(rows pgx.Rows) ([]domain.HistoryEntry, error) {
 	defer rows.Close()
 
 	out := make([]domain.HistoryEntry, 0)
 	for rows.Next() {
-		var e domain.HistoryEntry
-		var oldBytes, newBytes []byte
-		if err := rows.Scan(&e.ID, &e.ItemID, &e.Action, &e.Actor, &e.ActorRole, &e.ChangedAt, &oldBytes, &newBytes); err != nil {
+		e, err := scanHistory(rows)
+		if err != nil {
 			return nil, err
 		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
 
-		if len(oldBytes) > 0 {
-			var m any
-			if err := json.Unmarshal(oldBytes, &m); err == nil {
-				e.OldData = m
-			}
+// ListByBatch returns the entries written by one batch in the order they
+// were made.
+func (r *HistoryRepo) ListByBatch(ctx context.Context, batchID string) ([]domain.HistoryEntry, error) {
+	rows, err := r.pool.Query(ctx, `select `+historyColumns+` from items_history where batch_id=$1 order by id`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	return collectHistory(rows)
+}
+
+// ListBySerial returns the entries that changed a serial number, oldest
+// first.
+func (r *HistoryRepo) ListBySerial(ctx context.Context, serial string) ([]domain.HistoryEntry, error) {
+	rows, err := r.pool.Query(ctx, `
+select `+historyColumns+`
+from items_history
+where action = 'serial' and coalesce(new_data, old_data)->>'serial' = $1
+order by id`, serial)
+	if err != nil {
+		return nil, err
+	}
+	return collectHistory(rows)
+}
+
+func scanHistory(row pgx.Row) (domain.HistoryEntry, error) {
+	var e domain.HistoryEntry
+	var oldBytes, newBytes []byte
+	if err := row.Scan(&e.ID, &e.Seq, &e.ItemID, &e.Action, &e.Version, &e.Actor, &e.ActorRole, &e.CorrelationID, &e.SourceID, &e.BatchID, &e.ChangedAt, &oldBytes, &newBytes); err != nil {
+		return domain.HistoryEntry{}, err
+	}
+
+	if len(oldBytes) > 0 {
+		var m any
+		if err := json.Unmarshal(oldBytes, &m); err == nil {
+			e.OldData = m
 		}
-		if len(newBytes) > 0 {
-			var m any
-			if err := json.Unmarshal(newBytes, &m); err == nil {
-				e.NewData = m
-			}
+	}
+	if len(newBytes) > 0 {
+		var m any
+		if err := json.Unmarshal(newBytes, &m); err == nil {
+			e.NewData = m
 		}
+	}
+	return e, nil
+}
 
-		out = append(out, e)
+// ItemAsOf rebuilds the item from the last snapshot recorded at or before
+// asOf. ErrNotFound means the item did not exist at that moment.
+func (r *HistoryRepo) ItemAsOf(ctx context.Context, id int64, asOf time.Time) (domain.Item, error) {
+	var action string
+	var data []byte
+	err := r.pool.QueryRow(ctx, `
+select action, new_data
+from items_history
+where item_id = $1 and changed_at <= $2 and action not in ('lot', 'serial')
+order by changed_at desc, id desc
+limit 1`, id, asOf).Scan(&action, &data)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (action == "delete" || action == "purge")) {
+		return domain.Item{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	var it domain.Item
+	if err := json.Unmarshal(data, &it); err != nil {
+		return domain.Item{}, err
+	}
+	// reservations are not part of the history
+	it.OnHand, it.Available = it.Qty, it.Qty
+	return it, nil
+}
+
+// SnapshotAsOf rebuilds every item that existed at asOf, deleted ones
+// included, ordered by id.
+func (r *HistoryRepo) SnapshotAsOf(ctx context.Context, asOf time.Time) ([]domain.Item, error) {
+	rows, err := r.pool.Query(ctx, `
+select new_data
+from (
+  select distinct on (item_id) item_id, action, new_data
+  from items_history
+  where changed_at <= $1 and action not in ('lot', 'serial')
+  order by item_id, changed_at desc, id desc
+) last
+where action not in ('delete', 'purge')
+order by item_id`, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Item, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var it domain.Item
+		if err := json.Unmarshal(data, &it); err != nil {
+			return nil, err
+		}
+		it.OnHand, it.Available = it.Qty, it.Qty
+		out = append(out, it)
 	}
 	return out, rows.Err()
 }
@@ -3,7 +3,9 @@ package repo
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,7 +13,12 @@ import (
 	"warehouse/internal/domain"
 )
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrVersionMismatch = errors.New("version mismatch")
+	ErrInvalidSort     = errors.New("invalid sort field")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+)
 
 type ItemsRepo struct {
 	pool *pgxpool.Pool
@@ -21,57 +28,205 @@ func NewItemsRepo(db *DB) *ItemsRepo {
 	return &ItemsRepo{pool: db.Pool}
 }
 
-func (r *ItemsRepo) List(ctx context.Context, search string) ([]domain.Item, error) {
-	search = strings.TrimSpace(search)
+const itemColumns = `id, sku, name, qty, location, version, tracking, created_at, updated_at, deleted_at, deleted_by, ` + reservedExpr
 
-	q := `
-select id, sku, name, qty, location, created_at, updated_at
-from items
-`
+func scanItem(row pgx.Row) (domain.Item, error) {
+	var it domain.Item
+	err := row.Scan(&it.ID, &it.SKU, &it.Name, &it.Qty, &it.Location, &it.Version, &it.Tracking, &it.Created, &it.Updated, &it.DeletedAt, &it.DeletedBy, &it.Reserved)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Item{}, ErrNotFound
+	}
+	it.OnHand, it.Available = it.Qty, it.Qty-it.Reserved
+	return it, err
+}
+
+var itemSortColumns = map[domain.ItemSort]string{
+	domain.ItemSortID:        "id",
+	domain.ItemSortSKU:       "sku",
+	domain.ItemSortName:      "name",
+	domain.ItemSortQty:       "qty",
+	domain.ItemSortUpdatedAt: "updated_at",
+}
+
+// List returns one page of items using keyset pagination on (sort column, id)
+// together with the total number of rows matching the filters. Items in the
+// trash are left out unless f.IncludeDeleted is set.
+func (r *ItemsRepo) List(ctx context.Context, f domain.ItemQuery) (domain.ItemPage, error) {
+	col, ok := itemSortColumns[f.Sort]
+	if !ok {
+		return domain.ItemPage{}, ErrInvalidSort
+	}
+
+	where := ` where deleted_at is null`
+	if f.IncludeDeleted {
+		where = ` where true`
+	}
 	args := []any{}
-	if search != "" {
-		q += `where (sku ilike $1 or name ilike $1 or coalesce(location,'') ilike $1)`
-		args = append(args, "%"+search+"%")
+	idx := 1
+	arg := func(v any) string {
+		args = append(args, v)
+		s := `$` + strconv.Itoa(idx)
+		idx++
+		return s
+	}
+
+	if search := strings.TrimSpace(f.Search); search != "" {
+		p := arg("%" + search + "%")
+		where += ` and (sku ilike ` + p + ` or name ilike ` + p + ` or coalesce(location,'') ilike ` + p + `
+  or exists (
+    select 1 from stock_levels s join locations l on l.id = s.location_id
+    where s.item_id = items.id and s.qty <> 0 and l.code ilike ` + p + `
+  ))`
+	}
+	if f.QtyLT != nil {
+		where += ` and qty < ` + arg(*f.QtyLT)
+	}
+	if f.Location != nil {
+		where += ` and exists (
+    select 1 from stock_levels s join locations l on l.id = s.location_id
+    where s.item_id = items.id and l.code = ` + arg(*f.Location) + `
+  )`
+	}
+	if f.UpdatedSince != nil {
+		where += ` and updated_at >= ` + arg(*f.UpdatedSince)
+	}
+
+	var page domain.ItemPage
+	if err := r.pool.QueryRow(ctx, `select count(*) from items`+where, args...).Scan(&page.Total); err != nil {
+		return domain.ItemPage{}, err
+	}
+
+	dir, cmp := "asc", ">"
+	if f.Desc {
+		dir, cmp = "desc", "<"
+	}
+
+	q := `select ` + itemColumns + ` from items` + where
+	if f.After != nil {
+		v, err := cursorValue(f.Sort, f.After.Value)
+		if err != nil {
+			return domain.ItemPage{}, ErrInvalidCursor
+		}
+		q += ` and (` + col + `, id) ` + cmp + ` (` + arg(v) + `, ` + arg(f.After.ID) + `)`
 	}
-	q += ` order by id asc`
+	q += ` order by ` + col + ` ` + dir + `, id ` + dir + ` limit ` + arg(f.Limit+1)
 
 	rows, err := r.pool.Query(ctx, q, args...)
 	if err != nil {
-		return nil, err
+		return domain.ItemPage{}, err
 	}
 	defer rows.Close()
 
-	items := make([]domain.Item, 0)
+	page.Items = make([]domain.Item, 0, f.Limit)
 	for rows.Next() {
-		var it domain.Item
-		if err := rows.Scan(&it.ID, &it.SKU, &it.Name, &it.Qty, &it.Location, &it.Created, &it.Updated); err != nil {
-			return nil, err
+		it, err := scanItem(rows)
+		if err != nil {
+			return domain.ItemPage{}, err
 		}
-		items = append(items, it)
+		page.Items = append(page.Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.ItemPage{}, err
+	}
+
+	if len(page.Items) > f.Limit {
+		page.Items = page.Items[:f.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.Next = &domain.ItemCursor{Sort: f.Sort, Desc: f.Desc, Value: sortValue(f.Sort, last), ID: last.ID}
+	}
+	return page, nil
+}
+
+func sortValue(sort domain.ItemSort, it domain.Item) string {
+	switch sort {
+	case domain.ItemSortSKU:
+		return it.SKU
+	case domain.ItemSortName:
+		return it.Name
+	case domain.ItemSortQty:
+		return strconv.Itoa(it.Qty)
+	case domain.ItemSortUpdatedAt:
+		return it.Updated.Format(time.RFC3339Nano)
+	default:
+		return strconv.FormatInt(it.ID, 10)
+	}
+}
+
+func cursorValue(sort domain.ItemSort, v string) (any, error) {
+	switch sort {
+	case domain.ItemSortSKU, domain.ItemSortName:
+		return v, nil
+	case domain.ItemSortQty:
+		return strconv.Atoi(v)
+	case domain.ItemSortUpdatedAt:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		return strconv.ParseInt(v, 10, 64)
 	}
-	return items, rows.Err()
 }
 
+// Get returns the item unless it is in the trash, like every other lookup
+// except the WithDeleted ones.
 func (r *ItemsRepo) Get(ctx context.Context, id int64) (domain.Item, error) {
-	q := `select id, sku, name, qty, location, created_at, updated_at from items where id=$1`
-	var it domain.Item
-	err := r.pool.QueryRow(ctx, q, id).Scan(&it.ID, &it.SKU, &it.Name, &it.Qty, &it.Location, &it.Created, &it.Updated)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return domain.Item{}, ErrNotFound
+	return scanItem(r.pool.QueryRow(ctx, `select `+itemColumns+` from items where id=$1 and deleted_at is null`, id))
+}
+
+func (r *ItemsRepo) GetWithDeleted(ctx context.Context, id int64) (domain.Item, error) {
+	return scanItem(r.pool.QueryRow(ctx, `select `+itemColumns+` from items where id=$1`, id))
+}
+
+func (r *ItemsRepo) GetBySKU(ctx context.Context, sku string) (domain.Item, error) {
+	return scanItem(r.pool.QueryRow(ctx, `select `+itemColumns+` from items where sku=$1 and deleted_at is null`, sku))
+}
+
+func (r *ItemsRepo) GetBySKUForUpdate(ctx context.Context, tx pgx.Tx, sku string) (domain.Item, error) {
+	return scanItem(tx.QueryRow(ctx, `select `+itemColumns+` from items where sku=$1 and deleted_at is null for update`, sku))
+}
+
+// ListBySKUs returns the items among skus that exist, keyed by SKU.
+func (r *ItemsRepo) ListBySKUs(ctx context.Context, skus []string) (map[string]domain.Item, error) {
+	rows, err := r.pool.Query(ctx, `select `+itemColumns+` from items where sku = any($1) and deleted_at is null`, skus)
+	if err != nil {
+		return nil, err
 	}
-	return it, err
+	defer rows.Close()
+
+	out := make(map[string]domain.Item)
+	for rows.Next() {
+		it, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[it.SKU] = it
+	}
+	return out, rows.Err()
+}
+
+func (r *ItemsRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id int64) (domain.Item, error) {
+	return scanItem(tx.QueryRow(ctx, `select `+itemColumns+` from items where id=$1 and deleted_at is null for update`, id))
+}
+
+func (r *ItemsRepo) GetWithDeletedForUpdate(ctx context.Context, tx pgx.Tx, id int64) (domain.Item, error) {
+	return scanItem(tx.QueryRow(ctx, `select `+itemColumns+` from items where id=$1 for update`, id))
 }
 
 func (r *ItemsRepo) Create(ctx context.Context, tx pgx.Tx, in domain.ItemCreate) (domain.Item, error) {
 	q := `
-insert into items(sku, name, qty, location)
-values ($1,$2,$3,$4)
-returning id, sku, name, qty, location, created_at, updated_at
-`
-	var it domain.Item
-	err := tx.QueryRow(ctx, q, in.SKU, in.Name, in.Qty, in.Location).
-		Scan(&it.ID, &it.SKU, &it.Name, &it.Qty, &it.Location, &it.Created, &it.Updated)
-	return it, err
+insert into items(sku, name, qty, location, tracking)
+values ($1,$2,$3,$4,coalesce(nullif($5,''),'none'))
+returning ` + itemColumns
+	return scanItem(tx.QueryRow(ctx, q, in.SKU, in.Name, in.Qty, in.Location, in.Tracking))
+}
+
+// Recreate re-inserts a purged item under its original id. The version
+// continues from the last one recorded in history, so old ETags stay stale.
+func (r *ItemsRepo) Recreate(ctx context.Context, tx pgx.Tx, it domain.Item) (domain.Item, error) {
+	q := `
+insert into items(id, sku, name, qty, location, created_at, tracking, version)
+values ($1,$2,$3,$4,$5,$6,coalesce(nullif($7,''),'none'),
+  coalesce((select max(version) from items_history where item_id = $1), 0) + 1)
+returning ` + itemColumns
+	return scanItem(tx.QueryRow(ctx, q, it.ID, it.SKU, it.Name, it.Qty, it.Location, it.Created, it.Tracking))
 }
 
 func (r *ItemsRepo) Update(ctx context.Context, tx pgx.Tx, id int64, in domain.ItemUpdate) (domain.Item, error) {
@@ -79,24 +234,70 @@ func (r *ItemsRepo) Update(ctx context.Context, tx pgx.Tx, id int64, in domain.I
 update items
 set sku=$2, name=$3, qty=$4, location=$5
 where id=$1
-returning id, sku, name, qty, location, created_at, updated_at
-`
-	var it domain.Item
-	err := tx.QueryRow(ctx, q, id, in.SKU, in.Name, in.Qty, in.Location).
-		Scan(&it.ID, &it.SKU, &it.Name, &it.Qty, &it.Location, &it.Created, &it.Updated)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return domain.Item{}, ErrNotFound
-	}
-	return it, err
+returning ` + itemColumns
+	return scanItem(tx.QueryRow(ctx, q, id, in.SKU, in.Name, in.Qty, in.Location))
 }
 
-func (r *ItemsRepo) Delete(ctx context.Context, tx pgx.Tx, id int64) error {
-	ct, err := tx.Exec(ctx, `delete from items where id=$1`, id)
+func (r *ItemsRepo) SetTracking(ctx context.Context, tx pgx.Tx, id int64, tracking domain.Tracking) (domain.Item, error) {
+	return scanItem(tx.QueryRow(ctx, `update items set tracking=$2 where id=$1 returning `+itemColumns, id, tracking))
+}
+
+// Delete moves the item to the trash. When version is set the item is only
+// deleted if it still has that version, otherwise ErrVersionMismatch is
+// returned.
+func (r *ItemsRepo) Delete(ctx context.Context, tx pgx.Tx, id int64, version *int64, actor string) error {
+	ct, err := tx.Exec(ctx, `
+update items
+set deleted_at=now(), deleted_by=$3
+where id=$1 and deleted_at is null and ($2::bigint is null or version=$2)`, id, version, actor)
 	if err != nil {
 		return err
 	}
 	if ct.RowsAffected() == 0 {
+		if version != nil {
+			return r.missingOrStale(ctx, tx, id)
+		}
 		return ErrNotFound
 	}
 	return nil
 }
+
+func (r *ItemsRepo) missingOrStale(ctx context.Context, tx pgx.Tx, id int64) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, `select exists(select 1 from items where id=$1 and deleted_at is null)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
+// Restore takes the item out of the trash. ErrNotFound means there is no
+// such item in the trash.
+func (r *ItemsRepo) Restore(ctx context.Context, tx pgx.Tx, id int64) (domain.Item, error) {
+	q := `
+update items
+set deleted_at=null, deleted_by=null
+where id=$1 and deleted_at is not null
+returning ` + itemColumns
+	return scanItem(tx.QueryRow(ctx, q, id))
+}
+
+// Purge removes up to limit items that went to the trash before the given
+// time, together with their stock levels. History rows are kept.
+func (r *ItemsRepo) Purge(ctx context.Context, tx pgx.Tx, before time.Time, limit int) (int64, error) {
+	ct, err := tx.Exec(ctx, `
+delete from items
+where id in (
+  select id from items
+  where deleted_at < $1
+  order by deleted_at
+  limit $2
+  for update skip locked
+)`, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
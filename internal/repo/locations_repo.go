@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type LocationsRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewLocationsRepo(db *DB) *LocationsRepo {
+	return &LocationsRepo{pool: db.Pool}
+}
+
+const locationColumns = `id, parent_id, kind, code, name, created_at`
+
+func scanLocation(row pgx.Row) (domain.Location, error) {
+	var l domain.Location
+	err := row.Scan(&l.ID, &l.ParentID, &l.Kind, &l.Code, &l.Name, &l.Created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Location{}, ErrNotFound
+	}
+	return l, err
+}
+
+func (r *LocationsRepo) List(ctx context.Context, parentID *int64, kind *domain.LocationKind) ([]domain.Location, error) {
+	q := `
+select ` + locationColumns + `
+from locations
+where ($1::bigint is null or parent_id = $1)
+  and ($2::text is null or kind = $2)
+order by code asc
+`
+	rows, err := r.pool.Query(ctx, q, parentID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Location, 0)
+	for rows.Next() {
+		l, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func (r *LocationsRepo) Get(ctx context.Context, id int64) (domain.Location, error) {
+	return scanLocation(r.pool.QueryRow(ctx, `select `+locationColumns+` from locations where id=$1`, id))
+}
+
+func (r *LocationsRepo) GetByCode(ctx context.Context, tx pgx.Tx, code string) (domain.Location, error) {
+	return scanLocation(tx.QueryRow(ctx, `select `+locationColumns+` from locations where code=$1`, code))
+}
+
+func (r *LocationsRepo) Create(ctx context.Context, in domain.LocationCreate) (domain.Location, error) {
+	q := `
+insert into locations(parent_id, kind, code, name)
+values ($1,$2,$3,$4)
+returning ` + locationColumns
+	return scanLocation(r.pool.QueryRow(ctx, q, in.ParentID, in.Kind, in.Code, in.Name))
+}
+
+// EnsureBin returns the location with the given code, creating a top-level
+// bin for it when missing. It backs the free-text item location field.
+func (r *LocationsRepo) EnsureBin(ctx context.Context, tx pgx.Tx, code string) (domain.Location, error) {
+	q := `
+insert into locations(kind, code)
+values ('bin', $1)
+on conflict (code) do update set code = excluded.code
+returning ` + locationColumns
+	return scanLocation(tx.QueryRow(ctx, q, code))
+}
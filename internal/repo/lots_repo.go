@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type LotsRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewLotsRepo(db *DB) *LotsRepo {
+	return &LotsRepo{pool: db.Pool}
+}
+
+const lotColumns = `id, item_id, lot_number, manufactured_on, expires_on, supplier, qty, created_at, updated_at`
+
+func scanLot(row pgx.Row) (domain.Lot, error) {
+	var l domain.Lot
+	err := row.Scan(&l.ID, &l.ItemID, &l.Number, &l.ManufacturedOn, &l.ExpiresOn, &l.Supplier, &l.Qty, &l.Created, &l.Updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Lot{}, ErrNotFound
+	}
+	return l, err
+}
+
+func collectLots(rows pgx.Rows) ([]domain.Lot, error) {
+	defer rows.Close()
+
+	out := make([]domain.Lot, 0)
+	for rows.Next() {
+		l, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// ListByItem returns the lots of an item in FEFO order, empty ones only
+// when includeEmpty is set.
+func (r *LotsRepo) ListByItem(ctx context.Context, itemID int64, includeEmpty bool) ([]domain.Lot, error) {
+	rows, err := r.pool.Query(ctx, `
+select `+lotColumns+`
+from lots
+where item_id=$1 and ($2 or qty > 0)
+order by expires_on asc nulls last, id asc`, itemID, includeEmpty)
+	if err != nil {
+		return nil, err
+	}
+	return collectLots(rows)
+}
+
+func (r *LotsRepo) GetByNumberForUpdate(ctx context.Context, tx pgx.Tx, itemID int64, number string) (domain.Lot, error) {
+	return scanLot(tx.QueryRow(ctx, `select `+lotColumns+` from lots where item_id=$1 and lot_number=$2 for update`, itemID, number))
+}
+
+func (r *LotsRepo) Create(ctx context.Context, tx pgx.Tx, itemID int64, in domain.LotInput, qty int) (domain.Lot, error) {
+	q := `
+insert into lots(item_id, lot_number, manufactured_on, expires_on, supplier, qty)
+values ($1,$2,$3,$4,$5,$6)
+returning ` + lotColumns
+	return scanLot(tx.QueryRow(ctx, q, itemID, in.Number, in.ManufacturedOn, in.ExpiresOn, in.Supplier, qty))
+}
+
+// Add changes the quantity of the lot by delta.
+func (r *LotsRepo) Add(ctx context.Context, tx pgx.Tx, id int64, delta int) (domain.Lot, error) {
+	return scanLot(tx.QueryRow(ctx, `update lots set qty = qty + $2 where id=$1 returning `+lotColumns, id, delta))
+}
+
+// ListForIssue locks the lots of an item that still have stock and have not
+// expired by day, first-expired-first-out. Lots without an expiry date come
+// last.
+func (r *LotsRepo) ListForIssue(ctx context.Context, tx pgx.Tx, itemID int64, day time.Time) ([]domain.Lot, error) {
+	rows, err := tx.Query(ctx, `
+select `+lotColumns+`
+from lots
+where item_id=$1 and qty > 0 and (expires_on is null or expires_on >= $2::date)
+order by expires_on asc nulls last, manufactured_on asc nulls last, id asc
+for update`, itemID, day)
+	if err != nil {
+		return nil, err
+	}
+	return collectLots(rows)
+}
+
+// Total is the stock of the item held in lots.
+func (r *LotsRepo) Total(ctx context.Context, tx pgx.Tx, itemID int64) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `select coalesce(sum(qty), 0) from lots where item_id=$1`, itemID).Scan(&n)
+	return n, err
+}
+
+// ListExpiring returns the lots with stock that expire on or before until,
+// already expired ones included, soonest first. Items in the trash are
+// left out.
+func (r *LotsRepo) ListExpiring(ctx context.Context, today, until time.Time) ([]domain.ExpiringLot, error) {
+	rows, err := r.pool.Query(ctx, `
+select l.id, l.item_id, l.lot_number, l.manufactured_on, l.expires_on, l.supplier, l.qty, l.created_at, l.updated_at,
+  i.sku, i.name, l.expires_on - $1::date
+from lots l
+join items i on i.id = l.item_id and i.deleted_at is null
+where l.qty > 0 and l.expires_on <= $2::date
+order by l.expires_on asc, i.sku asc, l.lot_number asc`, today, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.ExpiringLot, 0)
+	for rows.Next() {
+		var e domain.ExpiringLot
+		if err := rows.Scan(&e.ID, &e.ItemID, &e.Number, &e.ManufacturedOn, &e.ExpiresOn, &e.Supplier, &e.Qty, &e.Created, &e.Updated,
+			&e.SKU, &e.Name, &e.DaysLeft); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type MovementsRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewMovementsRepo(db *DB) *MovementsRepo {
+	return &MovementsRepo{pool: db.Pool}
+}
+
+const movementColumns = `id, item_id, kind, qty_delta, qty_after, location, lot_id, lot_number, reason, reference, correlation_id, actor, actor_role, created_at`
+
+func scanMovement(row pgx.Row) (domain.Movement, error) {
+	var m domain.Movement
+	err := row.Scan(&m.ID, &m.ItemID, &m.Kind, &m.QtyDelta, &m.QtyAfter, &m.Location, &m.LotID, &m.LotNumber, &m.Reason,
+		&m.Reference, &m.CorrelationID, &m.Actor, &m.ActorRole, &m.Created)
+	return m, err
+}
+
+func (r *MovementsRepo) Insert(ctx context.Context, tx pgx.Tx, m domain.Movement) (domain.Movement, error) {
+	q := `
+insert into stock_movements(item_id, kind, qty_delta, qty_after, location, lot_id, lot_number, reason, reference, correlation_id, actor, actor_role)
+values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+returning ` + movementColumns
+	return scanMovement(tx.QueryRow(ctx, q, m.ItemID, m.Kind, m.QtyDelta, m.QtyAfter, m.Location, m.LotID, m.LotNumber, m.Reason,
+		m.Reference, m.CorrelationID, m.Actor, m.ActorRole))
+}
+
+func (r *MovementsRepo) ListByItem(ctx context.Context, itemID int64, kind *domain.MovementKind) ([]domain.Movement, error) {
+	q := `select ` + movementColumns + ` from stock_movements where item_id=$1`
+	args := []any{itemID}
+	if kind != nil {
+		q += ` and kind=$2`
+		args = append(args, *kind)
+	}
+	q += ` order by id desc`
+
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Movement, 0)
+	for rows.Next() {
+		m, err := scanMovement(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
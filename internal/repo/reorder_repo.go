@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type ReorderRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewReorderRepo(db *DB) *ReorderRepo {
+	return &ReorderRepo{pool: db.Pool}
+}
+
+const reorderColumns = `p.id, p.item_id, (select l.code from locations l where l.id = p.location_id),
+  p.min_qty, p.reorder_point, p.max_qty, p.alerted_at, p.created_at, p.updated_at`
+
+// reorderLevels adds the stock each reorder point is compared against:
+// available qty for item-wide points, the stock at the location otherwise.
+const reorderLevels = `
+select p.*, items.sku, items.name,
+  case when p.location_id is null then items.qty - ` + reservedExpr + `
+  else coalesce((select s.qty from stock_levels s where s.item_id = p.item_id and s.location_id = p.location_id), 0)
+  end as level
+from reorder_points p
+join items on items.id = p.item_id
+where items.deleted_at is null`
+
+func scanReorderPoint(row pgx.Row) (domain.ReorderPoint, error) {
+	var p domain.ReorderPoint
+	err := row.Scan(&p.ID, &p.ItemID, &p.Location, &p.MinQty, &p.ReorderPoint, &p.MaxQty, &p.AlertedAt, &p.Created, &p.Updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ReorderPoint{}, ErrNotFound
+	}
+	return p, err
+}
+
+func collectLowStock(rows pgx.Rows) ([]domain.LowStockLine, error) {
+	defer rows.Close()
+
+	out := make([]domain.LowStockLine, 0)
+	for rows.Next() {
+		var l domain.LowStockLine
+		p := &l.ReorderPoint
+		if err := rows.Scan(&p.ID, &p.ItemID, &p.Location, &p.MinQty, &p.ReorderPoint, &p.MaxQty, &p.AlertedAt, &p.Created, &p.Updated,
+			&l.SKU, &l.Name, &l.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// ListByItem returns the item-wide point first, then the per-location ones.
+func (r *ReorderRepo) ListByItem(ctx context.Context, itemID int64) ([]domain.ReorderPoint, error) {
+	rows, err := r.pool.Query(ctx, `
+select `+reorderColumns+`
+from reorder_points p
+where p.item_id=$1
+order by p.location_id nulls first`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.ReorderPoint, 0)
+	for rows.Next() {
+		p, err := scanReorderPoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Upsert sets the thresholds of the item at locationID. A pending alert is
+// dropped so that the new thresholds are evaluated from scratch.
+func (r *ReorderRepo) Upsert(ctx context.Context, tx pgx.Tx, itemID int64, locationID *int64, minQty, reorderPoint int, maxQty *int) (domain.ReorderPoint, error) {
+	q := `
+insert into reorder_points as p (item_id, location_id, min_qty, reorder_point, max_qty)
+values ($1,$2,$3,$4,$5)
+on conflict (item_id, (coalesce(location_id, 0))) do update
+set min_qty=excluded.min_qty, reorder_point=excluded.reorder_point, max_qty=excluded.max_qty, alerted_at=null
+returning ` + reorderColumns
+	return scanReorderPoint(tx.QueryRow(ctx, q, itemID, locationID, minQty, reorderPoint, maxQty))
+}
+
+func (r *ReorderRepo) Delete(ctx context.Context, tx pgx.Tx, itemID int64, locationID *int64) error {
+	ct, err := tx.Exec(ctx, `delete from reorder_points where item_id=$1 and coalesce(location_id, 0) = coalesce($2, 0)`, itemID, locationID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// LowStock returns the points at or below their reorder point, optionally
+// only those of one location, most urgent first.
+func (r *ReorderRepo) LowStock(ctx context.Context, location *string) ([]domain.LowStockLine, error) {
+	rows, err := r.pool.Query(ctx, `
+select `+reorderColumns+`, p.sku, p.name, p.level
+from (`+reorderLevels+`) p
+where p.level <= p.reorder_point
+  and ($1::text is null or p.location_id = (select l.id from locations l where l.code = $1))
+order by p.level - p.min_qty, p.sku, p.location_id nulls first`, location)
+	if err != nil {
+		return nil, err
+	}
+	return collectLowStock(rows)
+}
+
+// Flip claims the points of the given items (all items when itemIDs is
+// nil) whose stock crossed the reorder point since the last call: points
+// that became low get alerted_at set, points that recovered get it
+// cleared. Each crossing is returned to exactly one caller, also across
+// instances, since the row is re-checked under its lock.
+func (r *ReorderRepo) Flip(ctx context.Context, itemIDs []int64) ([]domain.LowStockLine, error) {
+	rows, err := r.pool.Query(ctx, `
+update reorder_points p
+set alerted_at = case when x.low then now() end
+from (
+  select lv.id, lv.sku, lv.name, lv.level, lv.level <= lv.reorder_point as low
+  from (`+reorderLevels+`) lv
+  where ($1::bigint[] is null or lv.item_id = any($1))
+) x
+where p.id = x.id and x.low <> (p.alerted_at is not null)
+returning `+reorderColumns+`, x.sku, x.name, x.level`, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return collectLowStock(rows)
+}
+
+// Rearm clears the alert of a point claimed by Flip at alertedAt, unless
+// the point changed since, so that the next Flip reports it again.
+func (r *ReorderRepo) Rearm(ctx context.Context, id int64, alertedAt time.Time) error {
+	_, err := r.pool.Exec(ctx, `update reorder_points set alerted_at = null where id=$1 and alerted_at=$2`, id, alertedAt)
+	return err
+}
@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type ReservationsRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewReservationsRepo(db *DB) *ReservationsRepo {
+	return &ReservationsRepo{pool: db.Pool}
+}
+
+// expiry is not written back: an active reservation past expires_at is
+// reported as expired and stops holding stock
+const reservationColumns = `id, item_id, qty, owner, reference,
+  case when status = 'active' and expires_at <= now() then 'expired' else status end,
+  expires_at, created_by, created_at, closed_by, closed_at`
+
+// reservedExpr sums the stock held by live reservations of items.id.
+const reservedExpr = `(select coalesce(sum(r.qty), 0) from reservations r
+  where r.item_id = items.id and r.status = 'active' and (r.expires_at is null or r.expires_at > now()))`
+
+func scanReservation(row pgx.Row) (domain.Reservation, error) {
+	var res domain.Reservation
+	err := row.Scan(&res.ID, &res.ItemID, &res.Qty, &res.Owner, &res.Reference, &res.Status,
+		&res.ExpiresAt, &res.CreatedBy, &res.Created, &res.ClosedBy, &res.ClosedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Reservation{}, ErrNotFound
+	}
+	return res, err
+}
+
+func (r *ReservationsRepo) Get(ctx context.Context, id int64) (domain.Reservation, error) {
+	return scanReservation(r.pool.QueryRow(ctx, `select `+reservationColumns+` from reservations where id=$1`, id))
+}
+
+func (r *ReservationsRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id int64) (domain.Reservation, error) {
+	return scanReservation(tx.QueryRow(ctx, `select `+reservationColumns+` from reservations where id=$1 for update`, id))
+}
+
+// List returns reservations matching the filter, newest first.
+func (r *ReservationsRepo) List(ctx context.Context, f domain.ReservationQuery) ([]domain.Reservation, error) {
+	where := ` where true`
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return `$` + strconv.Itoa(len(args))
+	}
+
+	if f.ItemID != nil {
+		where += ` and item_id = ` + arg(*f.ItemID)
+	}
+	if f.Owner != nil {
+		where += ` and owner = ` + arg(*f.Owner)
+	}
+	if f.Status != nil {
+		switch *f.Status {
+		case domain.ReservationActive:
+			where += ` and status = 'active' and (expires_at is null or expires_at > now())`
+		case domain.ReservationExpired:
+			where += ` and status = 'active' and expires_at <= now()`
+		default:
+			where += ` and status = ` + arg(string(*f.Status))
+		}
+	}
+
+	rows, err := r.pool.Query(ctx, `select `+reservationColumns+` from reservations`+where+` order by id desc`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Reservation, 0)
+	for rows.Next() {
+		res, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
+
+// SumActive is the stock held by live reservations of the item. Callers
+// lock the item first: a separate statement sees reservations committed
+// while it waited for the lock, a subquery of the locking select doesn't.
+func (r *ReservationsRepo) SumActive(ctx context.Context, tx pgx.Tx, itemID int64) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `
+select coalesce(sum(qty), 0)::int from reservations
+where item_id=$1 and status='active' and (expires_at is null or expires_at > now())`, itemID).Scan(&n)
+	return n, err
+}
+
+func (r *ReservationsRepo) Create(ctx context.Context, tx pgx.Tx, itemID int64, in domain.ReservationCreate, actor string) (domain.Reservation, error) {
+	q := `
+insert into reservations(item_id, qty, owner, reference, expires_at, created_by)
+values ($1,$2,$3,$4,$5,$6)
+returning ` + reservationColumns
+	return scanReservation(tx.QueryRow(ctx, q, itemID, in.Qty, in.Owner, in.Reference, in.ExpiresAt, actor))
+}
+
+// Close ends an active reservation as released or consumed.
+func (r *ReservationsRepo) Close(ctx context.Context, tx pgx.Tx, id int64, status domain.ReservationStatus, actor string) (domain.Reservation, error) {
+	q := `
+update reservations
+set status=$2, closed_by=$3, closed_at=now()
+where id=$1
+returning ` + reservationColumns
+	return scanReservation(tx.QueryRow(ctx, q, id, status, actor))
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type SerialsRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewSerialsRepo(db *DB) *SerialsRepo {
+	return &SerialsRepo{pool: db.Pool}
+}
+
+// serialHeldExpr is true while a reserved unit's reservation is live. Like
+// reservation expiry, the end of a reservation is not written back: the
+// unit reads as in_stock from then on.
+const serialHeldExpr = `(serials.status = 'reserved' and exists (select 1 from reservations r
+  where r.id = serials.reservation_id and r.status = 'active' and (r.expires_at is null or r.expires_at > now())))`
+
+const serialStatusExpr = `(case when serials.status = 'reserved' and not ` + serialHeldExpr + `
+  then 'in_stock' else serials.status end)`
+
+const serialColumns = `id, item_id, serial, ` + serialStatusExpr + `, location_id,
+  (select l.code from locations l where l.id = serials.location_id),
+  case when ` + serialHeldExpr + ` then reservation_id end, created_at, updated_at`
+
+func scanSerial(row pgx.Row) (domain.Serial, error) {
+	var s domain.Serial
+	err := row.Scan(&s.ID, &s.ItemID, &s.Serial, &s.Status, &s.LocationID, &s.Location, &s.ReservationID, &s.Created, &s.Updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Serial{}, ErrNotFound
+	}
+	return s, err
+}
+
+func (r *SerialsRepo) Get(ctx context.Context, serial string) (domain.Serial, error) {
+	return scanSerial(r.pool.QueryRow(ctx, `select `+serialColumns+` from serials where serial=$1`, serial))
+}
+
+func (r *SerialsRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, serial string) (domain.Serial, error) {
+	return scanSerial(tx.QueryRow(ctx, `select `+serialColumns+` from serials where serial=$1 for update`, serial))
+}
+
+// ListByItem returns the serials of an item, only those with the given
+// status when it is set.
+func (r *SerialsRepo) ListByItem(ctx context.Context, itemID int64, status *domain.SerialStatus) ([]domain.Serial, error) {
+	rows, err := r.pool.Query(ctx, `
+select `+serialColumns+`
+from serials
+where item_id=$1 and ($2::text is null or `+serialStatusExpr+`=$2)
+order by serial asc`, itemID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Serial, 0)
+	for rows.Next() {
+		s, err := scanSerial(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *SerialsRepo) Create(ctx context.Context, tx pgx.Tx, itemID int64, serial string, status domain.SerialStatus, locationID *int64) (domain.Serial, error) {
+	q := `
+insert into serials(item_id, serial, status, location_id)
+values ($1,$2,$3,$4)
+returning ` + serialColumns
+	return scanSerial(tx.QueryRow(ctx, q, itemID, serial, status, locationID))
+}
+
+// Update sets the status and location of a unit. A unit that stays reserved
+// keeps its reservation, any other status drops it.
+func (r *SerialsRepo) Update(ctx context.Context, tx pgx.Tx, id int64, status domain.SerialStatus, locationID *int64) (domain.Serial, error) {
+	q := `
+update serials
+set status=$2, location_id=$3, reservation_id=case when $2 = 'reserved' then reservation_id end
+where id=$1
+returning ` + serialColumns
+	return scanSerial(tx.QueryRow(ctx, q, id, status, locationID))
+}
+
+// Reserve holds the unit for the reservation.
+func (r *SerialsRepo) Reserve(ctx context.Context, tx pgx.Tx, id, reservationID int64) (domain.Serial, error) {
+	q := `
+update serials
+set status='reserved', reservation_id=$2
+where id=$1
+returning ` + serialColumns
+	return scanSerial(tx.QueryRow(ctx, q, id, reservationID))
+}
+
+// ListReserved returns the serial numbers the reservation holds.
+func (r *SerialsRepo) ListReserved(ctx context.Context, tx pgx.Tx, reservationID int64) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+select serial from serials
+where reservation_id=$1 and status='reserved'
+order by serial asc`, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var sn string
+		if err := rows.Scan(&sn); err != nil {
+			return nil, err
+		}
+		out = append(out, sn)
+	}
+	return out, rows.Err()
+}
+
+// Unreserve puts the units of the reservation back in stock.
+func (r *SerialsRepo) Unreserve(ctx context.Context, tx pgx.Tx, reservationID int64) error {
+	_, err := tx.Exec(ctx, `
+update serials
+set status='in_stock', reservation_id=null
+where reservation_id=$1 and status='reserved'`, reservationID)
+	return err
+}
+
+// CountOnHand is the number of units of the item in the warehouse.
+func (r *SerialsRepo) CountOnHand(ctx context.Context, tx pgx.Tx, itemID int64) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `
+select count(*) from serials
+where item_id=$1 and status in ('in_stock','reserved','returned')`, itemID).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type StockRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewStockRepo(db *DB) *StockRepo {
+	return &StockRepo{pool: db.Pool}
+}
+
+const stockLevelSelect = `
+select s.item_id, i.sku, i.name, s.location_id, l.code, s.qty
+from stock_levels s
+join items i on i.id = s.item_id and i.deleted_at is null
+left join locations l on l.id = s.location_id
+`
+
+func collectLevels(rows pgx.Rows) ([]domain.StockLevel, error) {
+	defer rows.Close()
+
+	out := make([]domain.StockLevel, 0)
+	for rows.Next() {
+		var lv domain.StockLevel
+		if err := rows.Scan(&lv.ItemID, &lv.SKU, &lv.Name, &lv.LocationID, &lv.LocationCode, &lv.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, lv)
+	}
+	return out, rows.Err()
+}
+
+func (r *StockRepo) ListByItem(ctx context.Context, itemID int64) ([]domain.StockLevel, error) {
+	rows, err := r.pool.Query(ctx, stockLevelSelect+`where s.item_id=$1 and s.qty <> 0 order by s.qty desc, l.code asc`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return collectLevels(rows)
+}
+
+// ListByLocationTree returns the stock stored at the location and at every
+// location below it in the hierarchy.
+func (r *StockRepo) ListByLocationTree(ctx context.Context, locationID int64) ([]domain.StockLevel, error) {
+	q := `
+with recursive tree as (
+  select id from locations where id = $1
+  union all
+  select l.id from locations l join tree t on l.parent_id = t.id
+)
+` + stockLevelSelect + `
+where s.location_id in (select id from tree) and s.qty <> 0
+order by l.code asc, i.sku asc
+`
+	rows, err := r.pool.Query(ctx, q, locationID)
+	if err != nil {
+		return nil, err
+	}
+	return collectLevels(rows)
+}
+
+// Add changes the quantity at one location by delta and returns the new
+// quantity there.
+func (r *StockRepo) Add(ctx context.Context, tx pgx.Tx, itemID int64, locationID *int64, delta int) (int, error) {
+	q := `
+insert into stock_levels(item_id, location_id, qty)
+values ($1,$2,$3)
+on conflict (item_id, (coalesce(location_id, 0))) do update set qty = stock_levels.qty + excluded.qty
+returning qty
+`
+	var qty int
+	err := tx.QueryRow(ctx, q, itemID, locationID, delta).Scan(&qty)
+	return qty, err
+}
+
+// CountLocations is the number of locations holding stock of the item.
+func (r *StockRepo) CountLocations(ctx context.Context, tx pgx.Tx, itemID int64) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `select count(*) from stock_levels where item_id=$1 and qty <> 0`, itemID).Scan(&n)
+	return n, err
+}
+
+// Collapse puts the whole stock of the item at one location. A zero level
+// is kept for a real location so the item remembers where it belongs.
+func (r *StockRepo) Collapse(ctx context.Context, tx pgx.Tx, itemID int64, locationID *int64, qty int) error {
+	if _, err := tx.Exec(ctx, `delete from stock_levels where item_id=$1`, itemID); err != nil {
+		return err
+	}
+	if qty == 0 && locationID == nil {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `insert into stock_levels(item_id, location_id, qty) values ($1,$2,$3)`, itemID, locationID, qty)
+	return err
+}
+
+// PrimaryLocation returns the code of the location holding most of the
+// item, which is what the legacy items.location field shows.
+func (r *StockRepo) PrimaryLocation(ctx context.Context, tx pgx.Tx, itemID int64) (*string, error) {
+	q := `
+select l.code
+from stock_levels s
+join locations l on l.id = s.location_id
+where s.item_id=$1
+order by s.qty desc, l.code asc
+limit 1
+`
+	var code *string
+	err := tx.QueryRow(ctx, q, itemID).Scan(&code)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return code, err
+}
@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type TokensRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewTokensRepo(db *DB) *TokensRepo {
+	return &TokensRepo{pool: db.Pool}
+}
+
+func (r *TokensRepo) Insert(ctx context.Context, tx pgx.Tx, hash string, t domain.RefreshToken) error {
+	q := `
+insert into refresh_tokens(token_hash, family_id, username, access_jti, access_expires_at, expires_at)
+values ($1,$2,$3,$4,$5,$6)
+`
+	_, err := tx.Exec(ctx, q, hash, t.FamilyID, t.Username, t.AccessJTI, t.AccessExp, t.ExpiresAt)
+	return err
+}
+
+func (r *TokensRepo) GetByHashForUpdate(ctx context.Context, tx pgx.Tx, hash string) (domain.RefreshToken, error) {
+	q := `
+select id, family_id, username, access_jti, access_expires_at, issued_at, expires_at, used_at, revoked_at
+from refresh_tokens
+where token_hash=$1
+for update
+`
+	var t domain.RefreshToken
+	err := tx.QueryRow(ctx, q, hash).Scan(&t.ID, &t.FamilyID, &t.Username, &t.AccessJTI, &t.AccessExp,
+		&t.IssuedAt, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.RefreshToken{}, ErrNotFound
+	}
+	return t, err
+}
+
+func (r *TokensRepo) MarkUsed(ctx context.Context, tx pgx.Tx, id int64) error {
+	_, err := tx.Exec(ctx, `update refresh_tokens set used_at=now() where id=$1`, id)
+	return err
+}
+
+// RevokeFamily revokes every refresh token of the family together with the
+// access tokens that were issued alongside them and have not expired yet.
+func (r *TokensRepo) RevokeFamily(ctx context.Context, tx pgx.Tx, familyID string) error {
+	return r.revokeWhere(ctx, tx, `family_id=$1`, familyID)
+}
+
+func (r *TokensRepo) RevokeUser(ctx context.Context, tx pgx.Tx, username string) error {
+	return r.revokeWhere(ctx, tx, `username=$1`, username)
+}
+
+func (r *TokensRepo) revokeWhere(ctx context.Context, tx pgx.Tx, cond string, arg any) error {
+	q := `
+insert into revoked_tokens(jti, expires_at)
+select access_jti, access_expires_at
+from refresh_tokens
+where ` + cond + ` and access_expires_at > now()
+on conflict (jti) do nothing
+`
+	if _, err := tx.Exec(ctx, q, arg); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `update refresh_tokens set revoked_at=now() where `+cond+` and revoked_at is null`, arg)
+	return err
+}
+
+func (r *TokensRepo) RevokeJTI(ctx context.Context, tx pgx.Tx, jti string, expiresAt time.Time) error {
+	q := `
+insert into revoked_tokens(jti, expires_at)
+values ($1,$2)
+on conflict (jti) do nothing
+`
+	_, err := tx.Exec(ctx, q, jti, expiresAt)
+	return err
+}
+
+// PurgeRevoked deletes denylist entries whose access token has expired by
+// now anyway.
+func (r *TokensRepo) PurgeRevoked(ctx context.Context) (int64, error) {
+	ct, err := r.pool.Exec(ctx, `delete from revoked_tokens where expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
+
+func (r *TokensRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := r.pool.QueryRow(ctx, `select exists(select 1 from revoked_tokens where jti=$1)`, jti).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type TransfersRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewTransfersRepo(db *DB) *TransfersRepo {
+	return &TransfersRepo{pool: db.Pool}
+}
+
+const transferSelect = `
+select t.id, t.status, f.code, d.code, t.reference, t.correlation_id, t.created_by,
+       t.created_at, t.dispatched_at, t.received_at
+from transfers t
+join locations f on f.id = t.from_location_id
+join locations d on d.id = t.to_location_id
+`
+
+func scanTransfer(row pgx.Row) (domain.Transfer, error) {
+	var t domain.Transfer
+	err := row.Scan(&t.ID, &t.Status, &t.FromLocation, &t.ToLocation, &t.Reference, &t.CorrelationID,
+		&t.CreatedBy, &t.Created, &t.DispatchedAt, &t.ReceivedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Transfer{}, ErrNotFound
+	}
+	return t, err
+}
+
+func (r *TransfersRepo) List(ctx context.Context, status *domain.TransferStatus) ([]domain.Transfer, error) {
+	rows, err := r.pool.Query(ctx, transferSelect+`where ($1::text is null or t.status = $1) order by t.id desc`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Transfer, 0)
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (r *TransfersRepo) Get(ctx context.Context, id int64) (domain.Transfer, error) {
+	t, err := scanTransfer(r.pool.QueryRow(ctx, transferSelect+`where t.id=$1`, id))
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	t.Lines, err = r.lines(ctx, r.pool, id)
+	return t, err
+}
+
+// GetForUpdate locks the transfer document so state changes are serialized.
+func (r *TransfersRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id int64) (domain.Transfer, error) {
+	t, err := scanTransfer(tx.QueryRow(ctx, transferSelect+`where t.id=$1 for update of t`, id))
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	t.Lines, err = r.lines(ctx, tx, id)
+	return t, err
+}
+
+func (r *TransfersRepo) Create(ctx context.Context, tx pgx.Tx, fromID, toID int64, reference *string, correlationID, createdBy string, lines []domain.TransferLine) (int64, error) {
+	q := `
+insert into transfers(status, from_location_id, to_location_id, reference, correlation_id, created_by)
+values ('draft',$1,$2,$3,$4,$5)
+returning id
+`
+	var id int64
+	if err := tx.QueryRow(ctx, q, fromID, toID, reference, correlationID, createdBy).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	for _, l := range lines {
+		if _, err := tx.Exec(ctx, `insert into transfer_lines(transfer_id, item_id, qty) values ($1,$2,$3)`, id, l.ItemID, l.Qty); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func (r *TransfersRepo) SetStatus(ctx context.Context, tx pgx.Tx, id int64, status domain.TransferStatus) error {
+	q := `
+update transfers
+set status = $2,
+    dispatched_at = case when $2 = 'in_transit' then now() else dispatched_at end,
+    received_at = case when $2 = 'received' then now() else received_at end
+where id = $1
+`
+	_, err := tx.Exec(ctx, q, id, status)
+	return err
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (r *TransfersRepo) lines(ctx context.Context, q querier, transferID int64) ([]domain.TransferLine, error) {
+	rows, err := q.Query(ctx, `
+select l.item_id, coalesce(i.sku, ''), l.qty
+from transfer_lines l
+left join items i on i.id = l.item_id
+where l.transfer_id = $1
+order by l.item_id asc
+`, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.TransferLine, 0)
+	for rows.Next() {
+		var l domain.TransferLine
+		if err := rows.Scan(&l.ItemID, &l.SKU, &l.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -15,3 +16,30 @@ func SetUserContext(ctx context.Context, tx pgx.Tx, username string, role string
 	}
 	return nil
 }
+
+// SetCorrelationID tags every history row written by the transaction, so
+// that changes belonging to one business operation can be found together.
+func SetCorrelationID(ctx context.Context, tx pgx.Tx, id string) error {
+	_, err := tx.Exec(ctx, "select set_config('app.correlation_id', $1, true)", id)
+	return err
+}
+
+// SetBatchID tags the history rows written by the transaction with the id
+// of the batch they belong to.
+func SetBatchID(ctx context.Context, tx pgx.Tx, id string) error {
+	_, err := tx.Exec(ctx, "select set_config('app.batch_id', $1, true)", id)
+	return err
+}
+
+// SetRevertOf marks item changes made by the transaction as a revert to the
+// snapshot stored in the given history row.
+func SetRevertOf(ctx context.Context, tx pgx.Tx, historyID int64) error {
+	_, err := tx.Exec(ctx, "select set_config('app.revert_of', $1, true)", strconv.FormatInt(historyID, 10))
+	return err
+}
+
+// ClearRevertOf undoes SetRevertOf for the rest of the transaction.
+func ClearRevertOf(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, "select set_config('app.revert_of', '', true)")
+	return err
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type UsersRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewUsersRepo(db *DB) *UsersRepo {
+	return &UsersRepo{pool: db.Pool}
+}
+
+const userColumns = `id, username, role, disabled, password_hash, created_at, updated_at`
+
+func scanUser(row pgx.Row) (domain.User, error) {
+	var u domain.User
+	var role string
+	err := row.Scan(&u.ID, &u.Username, &role, &u.Disabled, &u.PasswordHash, &u.Created, &u.Updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.User{}, ErrNotFound
+	}
+	u.Role = domain.Role(role)
+	return u, err
+}
+
+func (r *UsersRepo) List(ctx context.Context) ([]domain.User, error) {
+	rows, err := r.pool.Query(ctx, `select `+userColumns+` from users order by id asc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *UsersRepo) Get(ctx context.Context, id int64) (domain.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `select `+userColumns+` from users where id=$1`, id))
+}
+
+func (r *UsersRepo) GetByUsername(ctx context.Context, username string) (domain.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `select `+userColumns+` from users where username=$1`, username))
+}
+
+func (r *UsersRepo) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.pool.QueryRow(ctx, `select count(*) from users`).Scan(&n)
+	return n, err
+}
+
+func (r *UsersRepo) Create(ctx context.Context, username, passwordHash string, role domain.Role) (domain.User, error) {
+	q := `
+insert into users(username, password_hash, role)
+values ($1,$2,$3)
+returning ` + userColumns
+	return scanUser(r.pool.QueryRow(ctx, q, username, passwordHash, role.String()))
+}
+
+// GetForUpdate loads a user and locks the row until the end of tx.
+func (r *UsersRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id int64) (domain.User, error) {
+	return scanUser(tx.QueryRow(ctx, `select `+userColumns+` from users where id=$1 for update`, id))
+}
+
+// LockActiveAdmins locks every enabled admin until the end of tx and
+// returns how many there are.
+func (r *UsersRepo) LockActiveAdmins(ctx context.Context, tx pgx.Tx) (int, error) {
+	rows, err := tx.Query(ctx, `select id from users where role='admin' and not disabled order by id for update`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
+
+func (r *UsersRepo) SetRole(ctx context.Context, tx pgx.Tx, id int64, role domain.Role) (domain.User, error) {
+	q := `update users set role=$2 where id=$1 returning ` + userColumns
+	return scanUser(tx.QueryRow(ctx, q, id, role.String()))
+}
+
+func (r *UsersRepo) SetDisabled(ctx context.Context, tx pgx.Tx, id int64, disabled bool) (domain.User, error) {
+	q := `update users set disabled=$2 where id=$1 returning ` + userColumns
+	return scanUser(tx.QueryRow(ctx, q, id, disabled))
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"warehouse/internal/domain"
+)
+
+type WebhooksRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhooksRepo(db *DB) *WebhooksRepo {
+	return &WebhooksRepo{pool: db.Pool}
+}
+
+const webhookColumns = `id, url, secret, event_types, active, created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (domain.Webhook, error) {
+	var w domain.Webhook
+	err := row.Scan(&w.ID, &w.URL, &w.Secret, &w.EventTypes, &w.Active, &w.Created, &w.Updated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Webhook{}, ErrNotFound
+	}
+	return w, err
+}
+
+func (r *WebhooksRepo) List(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := r.pool.Query(ctx, `select `+webhookColumns+` from webhooks order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (r *WebhooksRepo) Create(ctx context.Context, in domain.WebhookInput) (domain.Webhook, error) {
+	q := `
+insert into webhooks(url, secret, event_types, active)
+values ($1,$2,$3,$4)
+returning ` + webhookColumns
+	return scanWebhook(r.pool.QueryRow(ctx, q, in.URL, in.Secret, in.EventTypes, in.Active))
+}
+
+// Update changes url, event types and the active flag; the secret is only
+// replaced when in.Secret is set.
+func (r *WebhooksRepo) Update(ctx context.Context, id int64, in domain.WebhookInput) (domain.Webhook, error) {
+	q := `
+update webhooks
+set url=$2, event_types=$3, active=$4, secret=coalesce(nullif($5, ''), secret)
+where id=$1
+returning ` + webhookColumns
+	return scanWebhook(r.pool.QueryRow(ctx, q, id, in.URL, in.EventTypes, in.Active, in.Secret))
+}
+
+func (r *WebhooksRepo) Delete(ctx context.Context, id int64) error {
+	ct, err := r.pool.Exec(ctx, `delete from webhooks where id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Enqueue writes an event to the outbox in the caller's transaction with a
+// pending delivery for every active webhook subscribed to its type. Nothing
+// is written when nobody is subscribed.
+func (r *WebhooksRepo) Enqueue(ctx context.Context, tx pgx.Tx, eventID, eventType string, itemID int64, payload []byte) error {
+	q := `
+with o as (
+  insert into webhook_outbox(event_id, event_type, item_id, payload)
+  select $1::text, $2::text, $3::bigint, $4::jsonb
+  where exists (select 1 from webhooks where active and $2 = any(event_types))
+  returning id
+)
+insert into webhook_deliveries(webhook_id, outbox_id)
+select w.id, o.id
+from webhooks w, o
+where w.active and $2 = any(w.event_types)
+`
+	_, err := tx.Exec(ctx, q, eventID, eventType, itemID, payload)
+	return err
+}
+
+const deliveryColumns = `d.id, d.webhook_id, o.event_id, o.event_type, d.status, d.attempts, d.next_attempt_at,
+  d.last_status, d.last_error, d.delivered_at, d.created_at`
+
+func (r *WebhooksRepo) ListDeliveries(ctx context.Context, webhookID int64, status *domain.DeliveryStatus, limit int) ([]domain.WebhookDelivery, error) {
+	q := `
+select ` + deliveryColumns + `
+from webhook_deliveries d
+join webhook_outbox o on o.id = d.outbox_id
+where d.webhook_id = $1 and ($2::text is null or d.status = $2)
+order by d.id desc
+limit $3
+`
+	rows, err := r.pool.Query(ctx, q, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatus, &d.LastError, &d.DeliveredAt, &d.Created); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Retry puts a delivery of the webhook back in the queue with a fresh
+// attempt budget, typically to replay a dead letter.
+func (r *WebhooksRepo) Retry(ctx context.Context, webhookID, id int64) error {
+	ct, err := r.pool.Exec(ctx, `
+update webhook_deliveries
+set status='pending', attempts=0, next_attempt_at=now(), last_error=null
+where id=$1 and webhook_id=$2`, id, webhookID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimDue leases up to limit due deliveries for lease so that several
+// dispatchers never send the same delivery at the same time.
+func (r *WebhooksRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboundDelivery, error) {
+	q := `
+update webhook_deliveries d
+set next_attempt_at = now() + $2 * interval '1 second'
+from webhooks w, webhook_outbox o
+where w.id = d.webhook_id and o.id = d.outbox_id
+  and d.id in (
+    select id from webhook_deliveries
+    where status = 'pending' and next_attempt_at <= now()
+    order by next_attempt_at
+    limit $1
+    for update skip locked
+  )
+returning d.id, d.attempts, w.url, w.secret, o.event_id, o.event_type, o.payload
+`
+	rows, err := r.pool.Query(ctx, q, limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.OutboundDelivery, 0)
+	for rows.Next() {
+		var d domain.OutboundDelivery
+		if err := rows.Scan(&d.ID, &d.Attempts, &d.URL, &d.Secret, &d.EventID, &d.EventType, &d.Payload); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *WebhooksRepo) MarkDelivered(ctx context.Context, id int64, status int) error {
+	_, err := r.pool.Exec(ctx, `
+update webhook_deliveries
+set status='delivered', attempts=attempts+1, last_status=$2, last_error=null, delivered_at=now()
+where id=$1`, id, status)
+	return err
+}
+
+// MarkFailed records a failed attempt. With next nil the delivery is dead
+// lettered, otherwise it is retried at next.
+func (r *WebhooksRepo) MarkFailed(ctx context.Context, id int64, status *int, reason string, next *time.Time) error {
+	_, err := r.pool.Exec(ctx, `
+update webhook_deliveries
+set attempts=attempts+1, last_status=$2, last_error=$3,
+    status=case when $4::timestamptz is null then 'dead' else 'pending' end,
+    next_attempt_at=coalesce($4, next_attempt_at)
+where id=$1`, id, status, reason, next)
+	return err
+}
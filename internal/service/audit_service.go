@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var ErrNoSigningKey = errors.New("no audit signing key configured")
+
+type AuditService struct {
+	repo   *repo.AuditRepo
+	signer crypto.Signer
+}
+
+// NewAuditService verifies the history hash chain. signer is optional and
+// only needed for checkpoints.
+func NewAuditService(r *repo.AuditRepo, signer crypto.Signer) *AuditService {
+	return &AuditService{repo: r, signer: signer}
+}
+
+func (s *AuditService) PublicKey() crypto.PublicKey {
+	if s.signer == nil {
+		return nil
+	}
+	return s.signer.Public()
+}
+
+// Verify walks the whole chain and stops at the first broken link: a row
+// without seq, a gap in seq, a prev_hash that doesn't point at the previous
+// row or a hash that doesn't match the row contents. Stored checkpoints are
+// checked against the chain along the way; without a signing key their
+// signatures can't be and the report says so.
+func (s *AuditService) Verify(ctx context.Context) (domain.ChainReport, error) {
+	checkpoints, err := s.repo.ListCheckpoints(ctx)
+	if err != nil {
+		return domain.ChainReport{}, err
+	}
+	bySeq := make(map[int64][]domain.Checkpoint, len(checkpoints))
+	for _, c := range checkpoints {
+		bySeq[c.LastSeq] = append(bySeq[c.LastSeq], c)
+	}
+
+	rep := domain.ChainReport{Signatures: domain.SignaturesVerified}
+	if s.signer == nil {
+		rep.Signatures = domain.SignaturesUnsigned
+	}
+	var prevHash []byte
+	stop := errors.New("stop")
+	fail := func(l domain.ChainLink, reason string) error {
+		rep.FirstBroken = &domain.ChainBreak{Seq: l.Seq, HistoryID: l.ID, Reason: reason}
+		return stop
+	}
+
+	err = s.repo.WalkChain(ctx, func(l domain.ChainLink) error {
+		switch {
+		case l.Seq == nil:
+			return fail(l, "entry is not part of the chain")
+		case *l.Seq != rep.HeadSeq+1:
+			return fail(l, "entries missing before seq "+strconv.FormatInt(*l.Seq, 10))
+		case !bytes.Equal(l.PrevHash, prevHash):
+			return fail(l, "prev_hash does not match the previous entry")
+		case !bytes.Equal(l.Hash, l.Expected):
+			return fail(l, "hash does not match the entry contents")
+		}
+
+		for _, c := range bySeq[*l.Seq] {
+			if c.LastHash != hex.EncodeToString(l.Hash) {
+				return fail(l, "entry differs from checkpoint of "+c.Day)
+			}
+			if s.signer != nil {
+				if err := s.verifySignature(c); err != nil {
+					return fail(l, "checkpoint of "+c.Day+": "+err.Error())
+				}
+			}
+			rep.CheckpointsChecked++
+		}
+
+		rep.Checked++
+		rep.HeadSeq = *l.Seq
+		prevHash = l.Hash
+		return nil
+	})
+	if err != nil && !errors.Is(err, stop) {
+		return domain.ChainReport{}, err
+	}
+
+	if rep.FirstBroken == nil {
+		for _, c := range checkpoints {
+			if c.LastSeq > rep.HeadSeq {
+				seq := c.LastSeq
+				rep.FirstBroken = &domain.ChainBreak{Seq: &seq, Reason: "chain ends before checkpoint of " + c.Day}
+				break
+			}
+		}
+	}
+
+	rep.OK = rep.FirstBroken == nil
+	if prevHash != nil {
+		rep.HeadHash = hex.EncodeToString(prevHash)
+	}
+	return rep, nil
+}
+
+// Checkpoints lists stored checkpoints with the signed message filled in.
+func (s *AuditService) Checkpoints(ctx context.Context) ([]domain.Checkpoint, error) {
+	cps, err := s.repo.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cps {
+		cps[i].Message = checkpointMessage(cps[i].Day, cps[i].LastSeq, cps[i].LastHash)
+	}
+	return cps, nil
+}
+
+// Checkpoint signs the last link of the chain written before the end of the
+// given UTC day. It returns false when the day already has a checkpoint or
+// nothing was written by then.
+func (s *AuditService) Checkpoint(ctx context.Context, day time.Time) (bool, error) {
+	if s.signer == nil {
+		return false, ErrNoSigningKey
+	}
+
+	start := day.UTC().Truncate(24 * time.Hour)
+	seq, hash, err := s.repo.HeadBefore(ctx, start.AddDate(0, 0, 1))
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	d := start.Format(time.DateOnly)
+	sig, err := signMessage(s.signer, []byte(checkpointMessage(d, seq, hex.EncodeToString(hash))))
+	if err != nil {
+		return false, err
+	}
+	return s.repo.InsertCheckpoint(ctx, start, seq, hash, sig)
+}
+
+// RunCheckpoints writes a checkpoint for the previous UTC day once it is
+// over, checking every interval until ctx is done.
+func (s *AuditService) RunCheckpoints(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		day := time.Now().UTC().AddDate(0, 0, -1)
+		created, err := s.Checkpoint(ctx, day)
+		if err != nil {
+			logger.Error("history checkpoint failed", "err", err)
+		} else if created {
+			logger.Info("history checkpoint written", "day", day.Format(time.DateOnly))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *AuditService) verifySignature(c domain.Checkpoint) error {
+	if s.signer == nil {
+		return ErrNoSigningKey
+	}
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return err
+	}
+	msg := []byte(checkpointMessage(c.Day, c.LastSeq, c.LastHash))
+	if !verifyMessage(s.signer.Public(), msg, sig) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func checkpointMessage(day string, seq int64, hash string) string {
+	return fmt.Sprintf("warehouse-history-checkpoint:%s:%d:%s", day, seq, hash)
+}
+
+// signMessage signs msg with Ed25519 directly, other keys sign its SHA-256
+// (PKCS#1 v1.5 for RSA), matching `openssl pkeyutl` / `openssl dgst -sha256`.
+func signMessage(signer crypto.Signer, msg []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	}
+	sum := sha256.Sum256(msg)
+	return signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+func verifyMessage(pub crypto.PublicKey, msg, sig []byte) bool {
+	sum := sha256.Sum256(msg)
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, msg, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, sum[:], sig)
+	default:
+		return false
+	}
+}
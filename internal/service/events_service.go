@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+// replayLimit caps how many missed entries are replayed on resume; a
+// client further behind is told to reload instead.
+const replayLimit = 1000
+
+// subscriberBuffer is how many events a slow subscriber may lag behind
+// before it is dropped and has to resume with Last-Event-ID.
+const subscriberBuffer = 256
+
+// EventsService fans committed history entries out to live subscribers. It
+// learns about new entries from Postgres LISTEN/NOTIFY, so changes made by
+// any instance or directly in the database are seen.
+type EventsService struct {
+	history *repo.HistoryRepo
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewEventsService(history *repo.HistoryRepo) *EventsService {
+	return &EventsService{history: history, subs: map[*Subscription]struct{}{}}
+}
+
+type Subscription struct {
+	C <-chan domain.HistoryEntry
+
+	ch     chan domain.HistoryEntry
+	events *EventsService
+	once   sync.Once
+}
+
+// Close stops delivery. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.events.drop(s)
+}
+
+func (s *EventsService) Subscribe() *Subscription {
+	ch := make(chan domain.HistoryEntry, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, events: s}
+
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	return sub
+}
+
+func (s *EventsService) drop(sub *Subscription) {
+	s.mu.Lock()
+	delete(s.subs, sub)
+	s.mu.Unlock()
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// Replay returns entries after chain position lastSeq. full is false when
+// more than replayLimit entries were missed and the client should reload.
+func (s *EventsService) Replay(ctx context.Context, lastSeq int64) (entries []domain.HistoryEntry, full bool, err error) {
+	entries, err = s.history.ListSince(ctx, lastSeq, replayLimit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(entries) > replayLimit {
+		return nil, false, nil
+	}
+	addChanges(entries)
+	return entries, true, nil
+}
+
+// Run listens for new history entries until ctx is done, reconnecting with
+// a growing delay when the connection drops.
+func (s *EventsService) Run(ctx context.Context, logger *slog.Logger) {
+	delay := time.Second
+	for {
+		started := time.Now()
+		err := s.history.Listen(ctx, func(id int64) { s.publish(ctx, logger, id) })
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error("change feed listener stopped", "err", err)
+
+		if time.Since(started) > time.Minute {
+			delay = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func (s *EventsService) publish(ctx context.Context, logger *slog.Logger, id int64) {
+	s.mu.Lock()
+	n := len(s.subs)
+	s.mu.Unlock()
+	if n == 0 {
+		return
+	}
+
+	e, err := s.history.Get(ctx, id)
+	if err != nil {
+		logger.Error("change feed: load history entry", "id", id, "err", err)
+		return
+	}
+	addChange(&e)
+
+	s.mu.Lock()
+	var slow []*Subscription
+	for sub := range s.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sub := range slow {
+		s.drop(sub)
+	}
+}
+
+// ItemEvent shapes a history entry for a subscriber with the given role:
+// viewers get the item state and the diff but not who made the change.
+func ItemEvent(e domain.HistoryEntry, role domain.Role) domain.ItemEvent {
+	ev := domain.ItemEvent{
+		ID:        e.ID,
+		ItemID:    e.ItemID,
+		Version:   e.Version,
+		ChangedAt: e.ChangedAt,
+		Item:      e.NewData,
+		Changes:   e.Changes,
+	}
+	switch e.Action {
+	case "insert":
+		ev.Type = domain.EventItemCreated
+	case "delete":
+		ev.Type = domain.EventItemDeleted
+		ev.Item = e.OldData
+	case "revert":
+		ev.Type = domain.EventItemReverted
+	case "restore":
+		ev.Type = domain.EventItemRestored
+	case "purge":
+		ev.Type = domain.EventItemPurged
+		ev.Item = e.OldData
+	case "lot":
+		ev.Type = domain.EventItemLotChanged
+		ev.Item, ev.Lot = nil, e.NewData
+		if e.NewData == nil {
+			ev.Lot = e.OldData
+		}
+	case "serial":
+		ev.Type = domain.EventItemSerialChanged
+		ev.Item, ev.Serial = nil, e.NewData
+		if e.NewData == nil {
+			ev.Serial = e.OldData
+		}
+	default:
+		ev.Type = domain.EventItemUpdated
+	}
+	if e.Seq != nil {
+		ev.Seq = *e.Seq
+	}
+	if role != domain.RoleViewer {
+		ev.Actor = e.Actor
+		ev.ActorRole = e.ActorRole
+	}
+	return ev
+}
+
+// MatchEvent reports whether the entry passes the subscriber's filter. The
+// location filter matches when the item was there before or after the
+// change.
+func MatchEvent(e domain.HistoryEntry, f domain.EventFilter) bool {
+	if f.ItemID != nil && e.ItemID != *f.ItemID {
+		return false
+	}
+	if f.Location != nil {
+		return snapshotLocation(e.OldData) == *f.Location || snapshotLocation(e.NewData) == *f.Location
+	}
+	return true
+}
+
+func snapshotLocation(v any) string {
+	m, _ := asMap(v)
+	loc, _ := m["location"].(string)
+	return loc
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"reflect"
+	"time"
 
 	"warehouse/internal/domain"
 	"warehouse/internal/repo"
@@ -23,29 +24,78 @@ func (s *HistoryService) ListByItem(ctx context.Context, itemID int64, filter do
 		return nil, err
 	}
 
-	if !includeChanges {
-		return entries, nil
+	if includeChanges {
+		addChanges(entries)
 	}
+	return entries, nil
+}
 
-	for i := range entries {
-		e := &entries[i]
-		if e.Action != "update" {
-			continue
-		}
+func (s *HistoryService) List(ctx context.Context, filter domain.HistoryFilter, after *domain.HistoryCursor, limit int, includeChanges bool) (domain.HistoryPage, error) {
+	page, err := s.repo.List(ctx, filter, after, limit)
+	if err != nil {
+		return domain.HistoryPage{}, err
+	}
 
-		oldMap, _ := asMap(e.OldData)
-		newMap, _ := asMap(e.NewData)
-		if oldMap == nil || newMap == nil {
-			continue
+	if includeChanges {
+		addChanges(page.Entries)
+	}
+	return page, nil
+}
+
+// ItemAsOf returns the item as it was at asOf, even if it has been deleted
+// since. repo.ErrNotFound means it didn't exist at that moment.
+func (s *HistoryService) ItemAsOf(ctx context.Context, id int64, asOf time.Time) (domain.Item, error) {
+	return s.repo.ItemAsOf(ctx, id, asOf)
+}
+
+func (s *HistoryService) SnapshotAsOf(ctx context.Context, asOf time.Time) (domain.InventorySnapshot, error) {
+	items, err := s.repo.SnapshotAsOf(ctx, asOf)
+	if err != nil {
+		return domain.InventorySnapshot{}, err
+	}
+
+	snap := domain.InventorySnapshot{AsOf: asOf, Items: items, ItemCount: len(items)}
+	for _, it := range items {
+		snap.TotalQty += int64(it.Qty)
+	}
+	return snap, nil
+}
+
+// Stream passes every entry matching filter to fn as it is read, so exports
+// of the whole log don't have to be held in memory.
+func (s *HistoryService) Stream(ctx context.Context, filter domain.HistoryFilter, includeChanges bool, fn func(domain.HistoryEntry) error) error {
+	return s.repo.Stream(ctx, filter, func(e domain.HistoryEntry) error {
+		if includeChanges {
+			addChange(&e)
 		}
+		return fn(e)
+	})
+}
 
-		e.Changes = diffMaps(oldMap, newMap, map[string]bool{
-			"updated_at": true,
-			"created_at": true,
-		})
+func addChanges(entries []domain.HistoryEntry) {
+	for i := range entries {
+		addChange(&entries[i])
 	}
+}
 
-	return entries, nil
+// addChange fills the field-level diff of an update, revert, lot or serial
+// entry.
+func addChange(e *domain.HistoryEntry) {
+	if e.Action != "update" && e.Action != "revert" && e.Action != "lot" && e.Action != "serial" {
+		return
+	}
+
+	oldMap, _ := asMap(e.OldData)
+	newMap, _ := asMap(e.NewData)
+	if oldMap == nil || newMap == nil {
+		return
+	}
+
+	e.Changes = diffMaps(oldMap, newMap, map[string]bool{
+		"updated_at": true,
+		"created_at": true,
+		"version":    true,
+	})
 }
 
 func asMap(v any) (map[string]any, bool) {
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+// BatchError tells which operation made a batch fail; nothing of the
+// batch was written.
+type BatchError struct {
+	Index  int
+	Op     domain.BatchOpKind
+	ItemID int64
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return "operation " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// Batch runs ops in order in one transaction: either all of them are
+// written or none. Every history row they produce carries the returned
+// batch id.
+func (s *ItemsService) Batch(ctx context.Context, actor string, role string, ops []domain.BatchOp) (domain.BatchResult, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.BatchResult{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	res := domain.BatchResult{BatchID: newCorrelationID(), Results: make([]domain.BatchOpResult, 0, len(ops))}
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.BatchResult{}, err
+	}
+	if err := repo.SetBatchID(ctx, tx, res.BatchID); err != nil {
+		return domain.BatchResult{}, err
+	}
+
+	for i, op := range ops {
+		r, err := s.batchOp(ctx, tx, actor, role, op)
+		if err != nil {
+			return domain.BatchResult{}, &BatchError{Index: i, Op: op.Kind, ItemID: op.ID, Err: err}
+		}
+		r.Index = i
+		res.Results = append(res.Results, r)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.BatchResult{}, err
+	}
+	s.lowStockChanged(res)
+	return res, nil
+}
+
+func (s *ItemsService) batchOp(ctx context.Context, tx pgx.Tx, actor, role string, op domain.BatchOp) (domain.BatchOpResult, error) {
+	r := domain.BatchOpResult{Op: op.Kind, ID: op.ID}
+	switch op.Kind {
+	case domain.BatchCreate:
+		it, err := s.create(ctx, tx, actor, role, op.Create)
+		if err != nil {
+			return r, err
+		}
+		r.ID, r.Item = it.ID, &it
+
+	case domain.BatchUpdate, domain.BatchPatch:
+		cur, err := s.repo.GetForUpdate(ctx, tx, op.ID)
+		if err != nil {
+			return r, err
+		}
+		if op.Version != nil && *op.Version != cur.Version {
+			return r, repo.ErrVersionMismatch
+		}
+		in, err := op.Apply(cur)
+		if err != nil {
+			return r, err
+		}
+		it, err := s.update(ctx, tx, actor, role, cur, in, domain.ReasonBatch)
+		if err != nil {
+			return r, err
+		}
+		r.Item = &it
+
+	case domain.BatchDelete:
+		if _, err := s.delete(ctx, tx, actor, op.ID, op.Version); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// RevertBatch puts every item touched by a batch back to its state from
+// before the batch: changed and deleted items get their old snapshot back,
+// items the batch created are deleted. Later changes to those items are
+// overwritten. The revert is itself a batch with a new id.
+func (s *ItemsService) RevertBatch(ctx context.Context, actor string, role string, batchID string) (domain.BatchResult, error) {
+	entries, err := s.history.ListByBatch(ctx, batchID)
+	if err != nil {
+		return domain.BatchResult{}, err
+	}
+	if len(entries) == 0 {
+		return domain.BatchResult{}, repo.ErrNotFound
+	}
+
+	// the first entry of each item holds its state before the batch
+	var first []domain.HistoryEntry
+	seen := map[int64]bool{}
+	for _, e := range entries {
+		if e.Action != "lot" && e.Action != "serial" && !seen[e.ItemID] {
+			seen[e.ItemID] = true
+			first = append(first, e)
+		}
+	}
+
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.BatchResult{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	res := domain.BatchResult{BatchID: newCorrelationID(), Results: make([]domain.BatchOpResult, 0, len(first))}
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.BatchResult{}, err
+	}
+	if err := repo.SetBatchID(ctx, tx, res.BatchID); err != nil {
+		return domain.BatchResult{}, err
+	}
+
+	// undo the items in reverse order of the batch
+	for i := len(first) - 1; i >= 0; i-- {
+		e := first[i]
+		r := domain.BatchOpResult{Index: len(res.Results), ID: e.ItemID}
+
+		if e.OldData == nil {
+			r.Op = domain.BatchDelete
+			// an earlier revertTo left app.revert_of set; the delete is not
+			// a revert to that entry
+			if err := repo.ClearRevertOf(ctx, tx); err != nil {
+				return domain.BatchResult{}, err
+			}
+			_, err := s.delete(ctx, tx, actor, e.ItemID, nil)
+			if errors.Is(err, repo.ErrNotFound) {
+				// already gone
+				continue
+			}
+			if err != nil {
+				return domain.BatchResult{}, &BatchError{Index: r.Index, Op: r.Op, ItemID: r.ID, Err: err}
+			}
+			res.Results = append(res.Results, r)
+			continue
+		}
+
+		target, err := snapshotItem(e.OldData)
+		if err != nil {
+			return domain.BatchResult{}, err
+		}
+		it, created, err := s.revertTo(ctx, tx, actor, role, e.ItemID, e.ID, target, nil)
+		if err != nil {
+			return domain.BatchResult{}, &BatchError{Index: r.Index, Op: domain.BatchUpdate, ItemID: r.ID, Err: err}
+		}
+		r.Op, r.Item = domain.BatchUpdate, &it
+		if created {
+			r.Op = domain.BatchCreate
+		}
+		res.Results = append(res.Results, r)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.BatchResult{}, err
+	}
+	s.lowStockChanged(res)
+	return res, nil
+}
+
+func (s *ItemsService) lowStockChanged(res domain.BatchResult) {
+	ids := make([]int64, 0, len(res.Results))
+	for _, r := range res.Results {
+		ids = append(ids, r.ID)
+	}
+	if len(ids) > 0 {
+		s.low.Changed(ids...)
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+// importRow is a validated import row. qty and location are nil when the
+// cell was empty: new items then start with 0 and no location, existing
+// ones keep theirs.
+type importRow struct {
+	result   *domain.ImportRowResult
+	sku      string
+	name     string
+	qty      *int
+	location *string
+}
+
+// Import upserts items by SKU. Every row is validated first; a dry run
+// only reports what would happen. In atomic mode nothing is written unless
+// all rows are valid and succeed, in best-effort mode each row is written
+// under its own savepoint and failures are reported per row. All history
+// entries of one import share a correlation id.
+func (s *ItemsService) Import(ctx context.Context, actor string, role string, rows []domain.ImportRow, mode domain.ImportMode, dryRun bool) (domain.ImportReport, error) {
+	rep := domain.ImportReport{
+		DryRun: dryRun,
+		Mode:   mode,
+		Total:  len(rows),
+		Rows:   make([]domain.ImportRowResult, len(rows)),
+	}
+
+	valid := make([]importRow, 0, len(rows))
+	firstLine := make(map[string]int, len(rows))
+	skus := make([]string, 0, len(rows))
+	for i, in := range rows {
+		res := &rep.Rows[i]
+		*res = domain.ImportRowResult{Line: in.Line, SKU: strings.TrimSpace(in.SKU)}
+		row, errs := parseImportRow(in)
+		if row.sku != "" {
+			if first, ok := firstLine[row.sku]; ok {
+				errs = append(errs, "duplicate sku, first seen on line "+strconv.Itoa(first))
+			} else {
+				firstLine[row.sku] = in.Line
+				skus = append(skus, row.sku)
+			}
+		}
+		if len(errs) > 0 {
+			res.Action, res.Errors = domain.ImportError, errs
+			continue
+		}
+		row.result = res
+		valid = append(valid, row)
+	}
+
+	existing, err := s.repo.ListBySKUs(ctx, skus)
+	if err != nil {
+		return domain.ImportReport{}, err
+	}
+	for _, row := range valid {
+		cur, ok := existing[row.sku]
+		row.result.Action = importAction(cur, ok, row)
+		if ok {
+			id := cur.ID
+			row.result.ItemID = &id
+		}
+	}
+
+	failed := len(rows) - len(valid)
+	if dryRun || (mode == domain.ImportAtomic && failed > 0) {
+		tally(&rep)
+		return rep, nil
+	}
+
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.ImportReport{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rep.CorrelationID = newCorrelationID()
+	if err := repo.SetCorrelationID(ctx, tx, rep.CorrelationID); err != nil {
+		return domain.ImportReport{}, err
+	}
+
+	for _, row := range valid {
+		if row.result.Action == domain.ImportUnchanged {
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return domain.ImportReport{}, err
+		}
+		err = s.importRow(ctx, sp, actor, role, row)
+		if err == nil {
+			err = sp.Commit(ctx)
+		}
+		if err != nil {
+			_ = sp.Rollback(ctx)
+			msg, ok := importRowError(err)
+			if !ok {
+				return domain.ImportReport{}, err
+			}
+			if row.result.Action == domain.ImportCreate {
+				row.result.ItemID = nil
+			}
+			row.result.Action, row.result.Errors = domain.ImportError, []string{msg}
+			if mode == domain.ImportAtomic {
+				// rolled back: items created so far don't exist
+				for i := range rep.Rows {
+					if rep.Rows[i].Action == domain.ImportCreate {
+						rep.Rows[i].ItemID = nil
+					}
+				}
+				rep.CorrelationID = ""
+				tally(&rep)
+				return rep, nil
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.ImportReport{}, err
+	}
+	rep.Committed = true
+	tally(&rep)
+	// a whole file may have changed, evaluate everything
+	s.low.Changed()
+	return rep, nil
+}
+
+// importRow writes one row under the savepoint tx. The item is locked and
+// compared again since it may have changed after the dry pass.
+func (s *ItemsService) importRow(ctx context.Context, tx pgx.Tx, actor, role string, row importRow) error {
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return err
+	}
+
+	cur, err := s.repo.GetBySKUForUpdate(ctx, tx, row.sku)
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		in := domain.ItemCreate{SKU: row.sku, Name: row.name, Location: row.location}
+		if row.qty != nil {
+			in.Qty = *row.qty
+		}
+		it, err := s.create(ctx, tx, actor, role, in)
+		if err != nil {
+			return err
+		}
+		row.result.Action, row.result.ItemID = domain.ImportCreate, &it.ID
+		return nil
+	case err != nil:
+		return err
+	}
+
+	row.result.ItemID = &cur.ID
+	row.result.Action = importAction(cur, true, row)
+	if row.result.Action == domain.ImportUnchanged {
+		return nil
+	}
+	_, err = s.update(ctx, tx, actor, role, cur, importUpdate(cur, row), domain.ReasonImport)
+	return err
+}
+
+func parseImportRow(in domain.ImportRow) (importRow, []string) {
+	row := importRow{
+		sku:  strings.TrimSpace(in.SKU),
+		name: strings.TrimSpace(in.Name),
+	}
+	var errs []string
+	if row.sku == "" {
+		errs = append(errs, "sku is required")
+	}
+	if row.name == "" {
+		errs = append(errs, "name is required")
+	}
+	if v := strings.TrimSpace(in.Qty); v != "" {
+		q, err := parseQty(v)
+		switch {
+		case err != nil:
+			errs = append(errs, "qty must be an integer")
+		case q < 0:
+			errs = append(errs, "qty must be >= 0")
+		default:
+			row.qty = &q
+		}
+	}
+	if v := strings.TrimSpace(in.Location); v != "" {
+		row.location = &v
+	}
+	return row, errs
+}
+
+// parseQty accepts integers, also as spreadsheets store them ("12.0").
+func parseQty(v string) (int, error) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, strconv.ErrSyntax
+	}
+	return int(f), nil
+}
+
+func importUpdate(cur domain.Item, row importRow) domain.ItemUpdate {
+	up := domain.ItemUpdate{SKU: cur.SKU, Name: row.name, Qty: cur.Qty, Location: cur.Location}
+	if row.qty != nil {
+		up.Qty = *row.qty
+	}
+	if row.location != nil {
+		up.Location = row.location
+	}
+	return up
+}
+
+func importAction(cur domain.Item, exists bool, row importRow) domain.ImportAction {
+	if !exists {
+		return domain.ImportCreate
+	}
+	up := importUpdate(cur, row)
+	if up.Name == cur.Name && up.Qty == cur.Qty && equalOptional(up.Location, cur.Location) {
+		return domain.ImportUnchanged
+	}
+	return domain.ImportUpdate
+}
+
+// importRowErrors are the validation errors creating or updating an item
+// can fail with because of the row's data.
+var importRowErrors = []error{
+	ErrSerialTracked, ErrNotAvailable, ErrMultiLocation, ErrBelowLots,
+	ErrUnknownLocation, ErrReservedLocation, ErrInsufficientStock,
+}
+
+// importRowError turns failures caused by the row's data into a message
+// for the report; anything else aborts the import.
+func importRowError(err error) (string, bool) {
+	for _, e := range importRowErrors {
+		if errors.Is(err, e) {
+			return e.Error(), true
+		}
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	switch {
+	case pgErr.Code == "23505":
+		return "sku must be unique", true
+	case pgErr.Code == "23514":
+		return "violates a check constraint", true
+	case strings.HasPrefix(pgErr.Code, "23"):
+		return "violates a constraint", true
+	case strings.HasPrefix(pgErr.Code, "22"):
+		return "invalid value", true
+	}
+	return "", false
+}
+
+func tally(rep *domain.ImportReport) {
+	rep.Created, rep.Updated, rep.Unchanged, rep.Failed = 0, 0, 0, 0
+	for _, r := range rep.Rows {
+		switch r.Action {
+		case domain.ImportCreate:
+			rep.Created++
+		case domain.ImportUpdate:
+			rep.Updated++
+		case domain.ImportUnchanged:
+			rep.Unchanged++
+		case domain.ImportError:
+			rep.Failed++
+		}
+	}
+}
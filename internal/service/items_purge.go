@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/repo"
+)
+
+const (
+	purgeActor     = "system"
+	purgeBatchSize = 500
+)
+
+// Purge permanently removes the items that have been in the trash for
+// longer than retention. Their history stays; each removal is audited as a
+// purge.
+func (s *ItemsService) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	before := time.Now().Add(-retention)
+
+	var total int64
+	for {
+		n, err := s.purgeBatch(ctx, before)
+		total += n
+		if err != nil || n < purgeBatchSize {
+			return total, err
+		}
+	}
+}
+
+func (s *ItemsService) purgeBatch(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, purgeActor, purgeActor); err != nil {
+		return 0, err
+	}
+	n, err := s.repo.Purge(ctx, tx, before, purgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit(ctx)
+}
+
+// RunPurge purges the trash every interval until ctx is done.
+func (s *ItemsService) RunPurge(ctx context.Context, retention, interval time.Duration, logger *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		n, err := s.Purge(ctx, retention)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("items purge failed", "err", err)
+		}
+		if n > 0 {
+			logger.Info("deleted items purged", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrNotAvailable      = errors.New("not enough available stock, the rest is reserved")
+	ErrReservationClosed = errors.New("reservation is no longer active")
+)
+
+// checkReserved fails with ErrNotAvailable when the locked item would be
+// left with less stock than its live reservations hold. Raising qty is
+// always fine.
+func checkReserved(ctx context.Context, tx pgx.Tx, reserve *repo.ReservationsRepo, cur domain.Item, qty int) error {
+	if qty >= cur.Qty {
+		return nil
+	}
+	reserved, err := reserve.SumActive(ctx, tx, cur.ID)
+	if err != nil {
+		return err
+	}
+	if qty < reserved {
+		return ErrNotAvailable
+	}
+	return nil
+}
+
+func (s *ItemsService) ListReservations(ctx context.Context, q domain.ReservationQuery) ([]domain.Reservation, error) {
+	return s.reserve.List(ctx, q)
+}
+
+func (s *ItemsService) GetReservation(ctx context.Context, id int64) (domain.Reservation, error) {
+	return s.reserve.Get(ctx, id)
+}
+
+// Reserve holds qty of the item for an owner. The item row stays locked
+// until commit, so concurrent reservations see each other and the last
+// unit can only be promised once.
+func (s *ItemsService) Reserve(ctx context.Context, actor string, role string, itemID int64, in domain.ReservationCreate) (domain.Reservation, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	cur, err := s.repo.GetForUpdate(ctx, tx, itemID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	reserved, err := s.reserve.SumActive(ctx, tx, itemID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	if in.Qty > cur.Qty-reserved {
+		return domain.Reservation{}, ErrNotAvailable
+	}
+
+	res, err := s.reserve.Create(ctx, tx, itemID, in, actor)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	if err := s.reserveSerials(ctx, tx, cur, res, in.Serials); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Reservation{}, err
+	}
+	s.low.Changed(res.ItemID)
+	return res, nil
+}
+
+// reserveSerials holds the named units of a serialized item for res. They
+// must be in stock; units of a reservation that has ended read as in stock.
+func (s *ItemsService) reserveSerials(ctx context.Context, tx pgx.Tx, cur domain.Item, res domain.Reservation, serials []string) error {
+	if len(serials) == 0 {
+		return nil
+	}
+	if cur.Tracking != domain.TrackingSerial {
+		return ErrNotSerialized
+	}
+	if len(serials) != res.Qty {
+		return ErrSerialsRequired
+	}
+	for _, sn := range serials {
+		unit, err := s.serials.GetForUpdate(ctx, tx, sn)
+		if errors.Is(err, repo.ErrNotFound) {
+			return &SerialError{Serial: sn, Err: ErrUnknownSerial}
+		}
+		if err != nil {
+			return err
+		}
+		if unit.ItemID != cur.ID || unit.Status != domain.SerialInStock {
+			return &SerialError{Serial: sn, Err: ErrSerialState}
+		}
+		if _, err := s.serials.Reserve(ctx, tx, unit.ID, res.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release gives the reserved stock, and the units it holds, back to
+// available.
+func (s *ItemsService) Release(ctx context.Context, actor string, role string, id int64) (domain.Reservation, error) {
+	res, err := s.reserve.Get(ctx, id)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	// item before reservation, the same order as Reserve and consuming
+	// movements lock in
+	if _, err := s.repo.GetWithDeletedForUpdate(ctx, tx, res.ItemID); err != nil {
+		return domain.Reservation{}, err
+	}
+	if res, err = s.reserve.GetForUpdate(ctx, tx, id); err != nil {
+		return domain.Reservation{}, err
+	}
+	if res.Status != domain.ReservationActive {
+		return domain.Reservation{}, ErrReservationClosed
+	}
+
+	if res, err = s.reserve.Close(ctx, tx, id, domain.ReservationReleased, actor); err != nil {
+		return domain.Reservation{}, err
+	}
+	if err := s.serials.Unreserve(ctx, tx, id); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Reservation{}, err
+	}
+	s.low.Changed(res.ItemID)
+	return res, nil
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 
 	"github.com/jackc/pgx/v5"
 
@@ -10,16 +12,62 @@ import (
 )
 
 type ItemsService struct {
-	db   *repo.DB
-	repo *repo.ItemsRepo
+	db        *repo.DB
+	repo      *repo.ItemsRepo
+	history   *repo.HistoryRepo
+	movements *repo.MovementsRepo
+	stock     *repo.StockRepo
+	locations *repo.LocationsRepo
+	lots      *repo.LotsRepo
+	serials   *repo.SerialsRepo
+	reserve   *repo.ReservationsRepo
+	hooks     *repo.WebhooksRepo
+
+	// told about committed stock changes, may be nil
+	low *LowStockService
+}
+
+func NewItemsService(db *repo.DB, r *repo.ItemsRepo, history *repo.HistoryRepo, movements *repo.MovementsRepo, stock *repo.StockRepo, locations *repo.LocationsRepo, lots *repo.LotsRepo, serials *repo.SerialsRepo, reserve *repo.ReservationsRepo, hooks *repo.WebhooksRepo, low *LowStockService) *ItemsService {
+	return &ItemsService{db: db, repo: r, history: history, movements: movements, stock: stock, locations: locations, lots: lots, serials: serials, reserve: reserve, hooks: hooks, low: low}
+}
+
+var (
+	ErrSerialTracked = errors.New("qty and location of a serialized item change only through movements of its serials")
+	ErrSerialCount   = errors.New("qty must equal the number of serials on hand")
+	ErrBelowLots     = errors.New("qty can't drop below the stock held in lots, issue from the lots instead")
+)
+
+// checkLots fails with ErrBelowLots when the locked item would be left with
+// less stock than its lots hold. Raising qty is always fine.
+func checkLots(ctx context.Context, tx pgx.Tx, lots *repo.LotsRepo, cur domain.Item, qty int) error {
+	if qty >= cur.Qty {
+		return nil
+	}
+	inLots, err := lots.Total(ctx, tx, cur.ID)
+	if err != nil {
+		return err
+	}
+	if qty < inLots {
+		return ErrBelowLots
+	}
+	return nil
 }
 
-func NewItemsService(db *repo.DB, r *repo.ItemsRepo) *ItemsService {
-	return &ItemsService{db: db, repo: r}
+func (s *ItemsService) List(ctx context.Context, q domain.ItemQuery) (domain.ItemPage, error) {
+	return s.repo.List(ctx, q)
 }
 
-func (s *ItemsService) List(ctx context.Context, search string) ([]domain.Item, error) {
-	return s.repo.List(ctx, search)
+func (s *ItemsService) Get(ctx context.Context, id int64) (domain.Item, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// GetWithDeleted also finds items in the trash.
+func (s *ItemsService) GetWithDeleted(ctx context.Context, id int64) (domain.Item, error) {
+	return s.repo.GetWithDeleted(ctx, id)
+}
+
+func (s *ItemsService) GetBySKU(ctx context.Context, sku string) (domain.Item, error) {
+	return s.repo.GetBySKU(ctx, sku)
 }
 
 func (s *ItemsService) Create(ctx context.Context, actor string, role string, in domain.ItemCreate) (domain.Item, error) {
@@ -33,18 +81,125 @@ func (s *ItemsService) Create(ctx context.Context, actor string, role string, in
 		return domain.Item{}, err
 	}
 
+	it, err := s.create(ctx, tx, actor, role, in)
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Item{}, err
+	}
+	s.low.Changed(it.ID)
+	return it, nil
+}
+
+// create inserts the item with its stock, ledger entry and webhook events
+// in the caller's transaction.
+func (s *ItemsService) create(ctx context.Context, tx pgx.Tx, actor, role string, in domain.ItemCreate) (domain.Item, error) {
+	if in.Tracking == domain.TrackingSerial && in.Qty != 0 {
+		return domain.Item{}, ErrSerialTracked
+	}
+
 	it, err := s.repo.Create(ctx, tx, in)
 	if err != nil {
 		return domain.Item{}, err
 	}
 
+	if _, err := setLegacyStock(ctx, tx, s.stock, s.locations, domain.Item{ID: it.ID}, domain.ItemUpdate{
+		Qty:      in.Qty,
+		Location: in.Location,
+	}); err != nil {
+		return domain.Item{}, err
+	}
+
+	if err := recordQtyChange(ctx, tx, s.movements, actor, role, it, 0, domain.MovementReceive, domain.ReasonInitial); err != nil {
+		return domain.Item{}, err
+	}
+	if err := queueItemEvents(ctx, tx, s.hooks, actor, nil, &it); err != nil {
+		return domain.Item{}, err
+	}
+	return it, nil
+}
+
+func (s *ItemsService) Update(ctx context.Context, actor string, role string, id int64, in domain.ItemUpdate, version *int64) (domain.Item, error) {
+	return s.Patch(ctx, actor, role, id, version, func(domain.Item) (domain.ItemUpdate, error) {
+		return in, nil
+	})
+}
+
+// Patch locks the item, lets apply compute the new state from the current
+// one and writes it back, so partial updates never race with other writers.
+func (s *ItemsService) Patch(ctx context.Context, actor string, role string, id int64, version *int64, apply func(domain.Item) (domain.ItemUpdate, error)) (domain.Item, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Item{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Item{}, err
+	}
+
+	cur, err := s.repo.GetForUpdate(ctx, tx, id)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	if version != nil && *version != cur.Version {
+		return domain.Item{}, repo.ErrVersionMismatch
+	}
+
+	in, err := apply(cur)
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	it, err := s.update(ctx, tx, actor, role, cur, in, domain.ReasonManualEdit)
+	if err != nil {
+		return domain.Item{}, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return domain.Item{}, err
 	}
+	s.low.Changed(it.ID)
 	return it, nil
 }
 
-func (s *ItemsService) Update(ctx context.Context, actor string, role string, id int64, in domain.ItemUpdate) (domain.Item, error) {
+// update writes in over the locked item cur together with its stock,
+// ledger entry and webhook events in the caller's transaction.
+func (s *ItemsService) update(ctx context.Context, tx pgx.Tx, actor, role string, cur domain.Item, in domain.ItemUpdate, reason string) (domain.Item, error) {
+	if cur.Tracking == domain.TrackingSerial && (in.Qty != cur.Qty || !equalOptional(in.Location, cur.Location)) {
+		return domain.Item{}, ErrSerialTracked
+	}
+	if err := checkReserved(ctx, tx, s.reserve, cur, in.Qty); err != nil {
+		return domain.Item{}, err
+	}
+	if err := checkLots(ctx, tx, s.lots, cur, in.Qty); err != nil {
+		return domain.Item{}, err
+	}
+
+	var err error
+	if in.Location, err = setLegacyStock(ctx, tx, s.stock, s.locations, cur, in); err != nil {
+		return domain.Item{}, err
+	}
+
+	it, err := s.repo.Update(ctx, tx, cur.ID, in)
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	if err := recordQtyChange(ctx, tx, s.movements, actor, role, it, cur.Qty, domain.MovementAdjust, reason); err != nil {
+		return domain.Item{}, err
+	}
+	if err := queueItemEvents(ctx, tx, s.hooks, actor, &cur, &it); err != nil {
+		return domain.Item{}, err
+	}
+	return it, nil
+}
+
+// SetTracking switches how the stock of the item is identified. An item
+// only becomes serialized when its qty equals the serials on hand.
+func (s *ItemsService) SetTracking(ctx context.Context, actor string, role string, id int64, tracking domain.Tracking, version *int64) (domain.Item, error) {
 	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return domain.Item{}, err
@@ -55,7 +210,28 @@ func (s *ItemsService) Update(ctx context.Context, actor string, role string, id
 		return domain.Item{}, err
 	}
 
-	it, err := s.repo.Update(ctx, tx, id, in)
+	cur, err := s.repo.GetForUpdate(ctx, tx, id)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	if version != nil && *version != cur.Version {
+		return domain.Item{}, repo.ErrVersionMismatch
+	}
+	if cur.Tracking == tracking {
+		return cur, nil
+	}
+
+	if tracking == domain.TrackingSerial {
+		n, err := s.serials.CountOnHand(ctx, tx, id)
+		if err != nil {
+			return domain.Item{}, err
+		}
+		if n != cur.Qty {
+			return domain.Item{}, ErrSerialCount
+		}
+	}
+
+	it, err := s.repo.SetTracking(ctx, tx, id, tracking)
 	if err != nil {
 		return domain.Item{}, err
 	}
@@ -66,7 +242,7 @@ func (s *ItemsService) Update(ctx context.Context, actor string, role string, id
 	return it, nil
 }
 
-func (s *ItemsService) Delete(ctx context.Context, actor string, role string, id int64) error {
+func (s *ItemsService) Delete(ctx context.Context, actor string, role string, id int64, version *int64) error {
 	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
@@ -77,9 +253,190 @@ func (s *ItemsService) Delete(ctx context.Context, actor string, role string, id
 		return err
 	}
 
-	if err := s.repo.Delete(ctx, tx, id); err != nil {
+	if _, err := s.delete(ctx, tx, actor, id, version); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
+
+// delete moves the item to the trash in the caller's transaction and
+// returns its last state.
+func (s *ItemsService) delete(ctx context.Context, tx pgx.Tx, actor string, id int64, version *int64) (domain.Item, error) {
+	cur, err := s.repo.GetForUpdate(ctx, tx, id)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	if err := s.repo.Delete(ctx, tx, id, version, actor); err != nil {
+		return domain.Item{}, err
+	}
+	if err := queueItemEvents(ctx, tx, s.hooks, actor, &cur, nil); err != nil {
+		return domain.Item{}, err
+	}
+	return cur, nil
+}
+
+var ErrNotDeleted = errors.New("item is not deleted")
+
+// Restore takes the item out of the trash as it was when it was deleted,
+// stock included.
+func (s *ItemsService) Restore(ctx context.Context, actor string, role string, id int64, version *int64) (domain.Item, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Item{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Item{}, err
+	}
+
+	cur, err := s.repo.GetWithDeletedForUpdate(ctx, tx, id)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	if cur.DeletedAt == nil {
+		return domain.Item{}, ErrNotDeleted
+	}
+	if version != nil && *version != cur.Version {
+		return domain.Item{}, repo.ErrVersionMismatch
+	}
+
+	it, err := s.repo.Restore(ctx, tx, id)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	if err := queueItemEvents(ctx, tx, s.hooks, actor, &cur, &it); err != nil {
+		return domain.Item{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Item{}, err
+	}
+	s.low.Changed(it.ID)
+	return it, nil
+}
+
+var ErrNothingToRevert = errors.New("history entry has no such snapshot")
+
+// Revert brings the item back to the snapshot stored in a history entry:
+// its old_data when useNew is false, otherwise its new_data. A deleted item
+// is taken out of the trash, a purged one is re-created under its original
+// id and SKU. The change is audited as a revert pointing at the source
+// entry. The entry is read after the item is locked, so it is checked
+// against the same state the revert is applied to.
+func (s *ItemsService) Revert(ctx context.Context, actor string, role string, id, historyID int64, useNew bool, version *int64) (domain.Item, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Item{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Item{}, err
+	}
+
+	// a purged item has no row left to lock
+	if _, err := s.repo.GetWithDeletedForUpdate(ctx, tx, id); err != nil && !errors.Is(err, repo.ErrNotFound) {
+		return domain.Item{}, err
+	}
+
+	e, err := s.history.GetInTx(ctx, tx, historyID)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	if e.ItemID != id {
+		return domain.Item{}, repo.ErrNotFound
+	}
+	if e.Action == "lot" || e.Action == "serial" {
+		return domain.Item{}, ErrNothingToRevert
+	}
+
+	snap := e.OldData
+	if useNew {
+		snap = e.NewData
+	}
+	if snap == nil {
+		return domain.Item{}, ErrNothingToRevert
+	}
+	target, err := snapshotItem(snap)
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	it, _, err := s.revertTo(ctx, tx, actor, role, id, historyID, target, version)
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Item{}, err
+	}
+	s.low.Changed(it.ID)
+	return it, nil
+}
+
+// revertTo writes target over item id in the caller's transaction, audited
+// as a revert of historyID. A deleted item is restored first and a purged
+// one re-created under its id; created tells whether the item came back.
+func (s *ItemsService) revertTo(ctx context.Context, tx pgx.Tx, actor, role string, id, historyID int64, target domain.Item, version *int64) (it domain.Item, created bool, err error) {
+	if err := repo.SetRevertOf(ctx, tx, historyID); err != nil {
+		return domain.Item{}, false, err
+	}
+
+	in := domain.ItemUpdate{SKU: target.SKU, Name: target.Name, Qty: target.Qty, Location: target.Location}
+
+	cur, err := s.repo.GetWithDeletedForUpdate(ctx, tx, id)
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		target.ID = id
+		// the purge took the serials with it
+		if target.Tracking == domain.TrackingSerial {
+			target.Tracking = domain.TrackingNone
+		}
+		if it, err = s.repo.Recreate(ctx, tx, target); err != nil {
+			return domain.Item{}, false, err
+		}
+		if _, err := setLegacyStock(ctx, tx, s.stock, s.locations, domain.Item{ID: id}, in); err != nil {
+			return domain.Item{}, false, err
+		}
+		if err := recordQtyChange(ctx, tx, s.movements, actor, role, it, 0, domain.MovementReceive, domain.ReasonRevert); err != nil {
+			return domain.Item{}, false, err
+		}
+		if err := queueItemEvents(ctx, tx, s.hooks, actor, nil, &it); err != nil {
+			return domain.Item{}, false, err
+		}
+		return it, true, nil
+	case err != nil:
+		return domain.Item{}, false, err
+	}
+
+	if version != nil && *version != cur.Version {
+		return domain.Item{}, false, repo.ErrVersionMismatch
+	}
+
+	if cur.DeletedAt != nil {
+		deleted := cur
+		if cur, err = s.repo.Restore(ctx, tx, id); err != nil {
+			return domain.Item{}, false, err
+		}
+		if err := queueItemEvents(ctx, tx, s.hooks, actor, &deleted, &cur); err != nil {
+			return domain.Item{}, false, err
+		}
+		created = true
+	}
+
+	it, err = s.update(ctx, tx, actor, role, cur, in, domain.ReasonRevert)
+	return it, created, err
+}
+
+// snapshotItem decodes an item snapshot stored in items_history.
+func snapshotItem(snap any) (domain.Item, error) {
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		return domain.Item{}, err
+	}
+	var it domain.Item
+	err = json.Unmarshal(raw, &it)
+	return it, err
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var ErrInvalidParent = errors.New("parent must be the next level up: warehouse > zone > aisle > bin")
+
+type LocationsService struct {
+	repo  *repo.LocationsRepo
+	stock *repo.StockRepo
+}
+
+func NewLocationsService(r *repo.LocationsRepo, stock *repo.StockRepo) *LocationsService {
+	return &LocationsService{repo: r, stock: stock}
+}
+
+func (s *LocationsService) List(ctx context.Context, parentID *int64, kind *domain.LocationKind) ([]domain.Location, error) {
+	return s.repo.List(ctx, parentID, kind)
+}
+
+// Create adds a location below its parent. Warehouses are roots; bins may be
+// roots too because the legacy item location field creates them on the fly.
+// The transit bin is created by transfers only.
+func (s *LocationsService) Create(ctx context.Context, in domain.LocationCreate) (domain.Location, error) {
+	in.Code = strings.TrimSpace(in.Code)
+	if isTransit(&in.Code) {
+		return domain.Location{}, ErrReservedLocation
+	}
+
+	if in.ParentID == nil {
+		if in.Kind != domain.LocationWarehouse && in.Kind != domain.LocationBin {
+			return domain.Location{}, ErrInvalidParent
+		}
+		return s.repo.Create(ctx, in)
+	}
+
+	parent, err := s.repo.Get(ctx, *in.ParentID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return domain.Location{}, ErrInvalidParent
+		}
+		return domain.Location{}, err
+	}
+	if parent.Kind.Depth() != in.Kind.Depth()-1 {
+		return domain.Location{}, ErrInvalidParent
+	}
+	return s.repo.Create(ctx, in)
+}
+
+func (s *LocationsService) Stock(ctx context.Context, id int64) (domain.LocationStock, error) {
+	l, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.LocationStock{}, err
+	}
+
+	levels, err := s.stock.ListByLocationTree(ctx, id)
+	if err != nil {
+		return domain.LocationStock{}, err
+	}
+
+	out := domain.LocationStock{Location: l, Levels: levels}
+	for _, lv := range levels {
+		out.Total += lv.Qty
+	}
+	return out, nil
+}
+
+func (s *LocationsService) ItemStock(ctx context.Context, itemID int64) (domain.ItemStock, error) {
+	levels, err := s.stock.ListByItem(ctx, itemID)
+	if err != nil {
+		return domain.ItemStock{}, err
+	}
+
+	out := domain.ItemStock{ItemID: itemID, Levels: levels}
+	for _, lv := range levels {
+		out.Total += lv.Qty
+	}
+	return out, nil
+}
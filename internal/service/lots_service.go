@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+type LotsService struct {
+	repo *repo.LotsRepo
+}
+
+func NewLotsService(r *repo.LotsRepo) *LotsService {
+	return &LotsService{repo: r}
+}
+
+func (s *LotsService) ListByItem(ctx context.Context, itemID int64, includeEmpty bool) ([]domain.Lot, error) {
+	return s.repo.ListByItem(ctx, itemID, includeEmpty)
+}
+
+// Expiring lists the lots with stock that expire within the given time
+// from today (UTC), together with the last day covered.
+func (s *LotsService) Expiring(ctx context.Context, within time.Duration) ([]domain.ExpiringLot, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	until := today.Add(within)
+	lots, err := s.repo.ListExpiring(ctx, today, until)
+	return lots, until, err
+}
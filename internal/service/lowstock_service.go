@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var ErrInvalidThresholds = errors.New("thresholds must satisfy 0 <= min_qty <= reorder_point <= max_qty")
+
+// Notifier delivers low-stock alerts. Notify is called from the evaluator
+// goroutine, one alert at a time.
+type Notifier interface {
+	Notify(ctx context.Context, alert domain.LowStockLine) error
+}
+
+// LowStockService keeps the reorder points and raises an alert through
+// every notifier when the stock of a point drops to its reorder point. An
+// alert fires once per crossing; it is re-armed when the stock recovers.
+type LowStockService struct {
+	db        *repo.DB
+	repo      *repo.ReorderRepo
+	items     *repo.ItemsRepo
+	locations *repo.LocationsRepo
+	notifiers []Notifier
+
+	// items changed since the last evaluation, all of them when all is set
+	mu      sync.Mutex
+	pending map[int64]bool
+	all     bool
+	wake    chan struct{}
+}
+
+func NewLowStockService(db *repo.DB, r *repo.ReorderRepo, items *repo.ItemsRepo, locations *repo.LocationsRepo, notifiers ...Notifier) *LowStockService {
+	return &LowStockService{
+		db:        db,
+		repo:      r,
+		items:     items,
+		locations: locations,
+		notifiers: notifiers,
+		pending:   map[int64]bool{},
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func (s *LowStockService) ListByItem(ctx context.Context, itemID int64) ([]domain.ReorderPoint, error) {
+	if _, err := s.items.Get(ctx, itemID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByItem(ctx, itemID)
+}
+
+// Set creates or replaces the reorder point of the item at in.Location.
+func (s *LowStockService) Set(ctx context.Context, itemID int64, in domain.ReorderPointInput) (domain.ReorderPoint, error) {
+	point := in.MinQty
+	if in.ReorderPoint != nil {
+		point = *in.ReorderPoint
+	}
+	if in.MinQty < 0 || point < in.MinQty || (in.MaxQty != nil && *in.MaxQty < point) {
+		return domain.ReorderPoint{}, ErrInvalidThresholds
+	}
+
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.ReorderPoint{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := s.items.GetForUpdate(ctx, tx, itemID); err != nil {
+		return domain.ReorderPoint{}, err
+	}
+	locID, err := resolveLocation(ctx, tx, s.locations, in.Location)
+	if err != nil {
+		return domain.ReorderPoint{}, err
+	}
+
+	p, err := s.repo.Upsert(ctx, tx, itemID, locID, in.MinQty, point, in.MaxQty)
+	if err != nil {
+		return domain.ReorderPoint{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.ReorderPoint{}, err
+	}
+	s.Changed(itemID)
+	return p, nil
+}
+
+func (s *LowStockService) Delete(ctx context.Context, itemID int64, location *string) error {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	locID, err := resolveLocation(ctx, tx, s.locations, location)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, tx, itemID, locID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Report lists every point at or below its reorder point, most urgent
+// first.
+func (s *LowStockService) Report(ctx context.Context, location *string) ([]domain.LowStockLine, error) {
+	lines, err := s.repo.LowStock(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		assess(&lines[i])
+	}
+	return lines, nil
+}
+
+// assess fills the severity and the qty to order up to max_qty, or up to
+// the reorder point when there is no max.
+func assess(l *domain.LowStockLine) {
+	l.Severity = domain.LowStockReorder
+	if l.Qty < l.MinQty {
+		l.Severity = domain.LowStockCritical
+	}
+	target := l.ReorderPoint.ReorderPoint
+	if l.MaxQty != nil {
+		target = *l.MaxQty
+	}
+	l.SuggestedQty = max(target-l.Qty, 0)
+}
+
+// Changed tells the evaluator that the stock of the items may have moved;
+// without ids every item is evaluated. It never blocks and is a no-op on a
+// nil service.
+func (s *LowStockService) Changed(ids ...int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if len(ids) == 0 {
+		s.all = true
+	}
+	for _, id := range ids {
+		s.pending[id] = true
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run evaluates the changed items as they come in and all items every
+// interval, which also catches stock changed outside this service's
+// callers, until ctx is done.
+func (s *LowStockService) Run(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	s.Changed()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-t.C:
+			s.Changed()
+			continue
+		}
+
+		if err := s.evaluate(ctx, s.take(), logger); err != nil && ctx.Err() == nil {
+			logger.Error("low stock evaluation failed", "err", err)
+		}
+	}
+}
+
+// take returns the items to evaluate, nil for all of them.
+func (s *LowStockService) take() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int64
+	if !s.all {
+		ids = make([]int64, 0, len(s.pending))
+		for id := range s.pending {
+			ids = append(ids, id)
+		}
+	}
+	s.all = false
+	clear(s.pending)
+	return ids
+}
+
+func (s *LowStockService) evaluate(ctx context.Context, ids []int64, logger *slog.Logger) error {
+	if ids != nil && len(ids) == 0 {
+		return nil
+	}
+	crossed, err := s.repo.Flip(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, l := range crossed {
+		if l.AlertedAt == nil {
+			continue
+		}
+		assess(&l)
+		failed := 0
+		for _, n := range s.notifiers {
+			if err := n.Notify(ctx, l); err != nil {
+				logger.Error("low stock notification failed", "notifier", fmt.Sprintf("%T", n), "sku", l.SKU, "err", err)
+				failed++
+			}
+		}
+		// nobody heard of it: re-arm so the next evaluation tries again
+		if failed > 0 && failed == len(s.notifiers) {
+			if err := s.repo.Rearm(ctx, l.ID, *l.AlertedAt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
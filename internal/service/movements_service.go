@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrSameLocation      = errors.New("source and destination location are the same")
+	ErrUnknownLot        = errors.New("unknown lot")
+	ErrLotMismatch       = errors.New("lot already exists with other dates or supplier")
+	ErrLotRequired       = errors.New("lot-tracked items are received into a lot")
+	ErrSerialsRequired   = errors.New("serialized items need one serial per unit")
+	ErrNotSerialized     = errors.New("item is not serialized")
+	ErrUnknownSerial     = errors.New("unknown serial")
+	ErrSerialState       = errors.New("serial can't take part in this movement")
+	ErrSerialNotNamed    = errors.New("serial is reserved for this reservation and must be shipped with it")
+)
+
+// SerialError names the serial a movement failed on.
+type SerialError struct {
+	Serial string
+	Err    error
+}
+
+func (e *SerialError) Error() string { return "serial " + e.Serial + ": " + e.Err.Error() }
+
+func (e *SerialError) Unwrap() error { return e.Err }
+
+type MovementsService struct {
+	db        *repo.DB
+	items     *repo.ItemsRepo
+	repo      *repo.MovementsRepo
+	stock     *repo.StockRepo
+	locations *repo.LocationsRepo
+	lots      *repo.LotsRepo
+	serials   *repo.SerialsRepo
+	reserve   *repo.ReservationsRepo
+	hooks     *repo.WebhooksRepo
+	low       *LowStockService
+}
+
+func NewMovementsService(db *repo.DB, items *repo.ItemsRepo, r *repo.MovementsRepo, stock *repo.StockRepo, locations *repo.LocationsRepo, lots *repo.LotsRepo, serials *repo.SerialsRepo, reserve *repo.ReservationsRepo, hooks *repo.WebhooksRepo, low *LowStockService) *MovementsService {
+	return &MovementsService{db: db, items: items, repo: r, stock: stock, locations: locations, lots: lots, serials: serials, reserve: reserve, hooks: hooks, low: low}
+}
+
+func (s *MovementsService) ListByItem(ctx context.Context, itemID int64, kind *domain.MovementKind) ([]domain.Movement, error) {
+	return s.repo.ListByItem(ctx, itemID, kind)
+}
+
+// Apply books a stock operation at a location (the item's primary location
+// by default) and updates items.qty from it in the same transaction. Only
+// admins may drive the stock at a location below zero. Issues and negative
+// adjusts may not take reserved stock, not even for admins, unless the issue
+// consumes the reservation holding it.
+func (s *MovementsService) Apply(ctx context.Context, actor string, role string, itemID int64, req domain.MovementRequest) (domain.Item, []domain.Movement, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Item{}, nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Item{}, nil, err
+	}
+
+	cur, err := s.items.GetForUpdate(ctx, tx, itemID)
+	if err != nil {
+		return domain.Item{}, nil, err
+	}
+	if req.ReservationID != nil {
+		res, err := s.reserve.GetForUpdate(ctx, tx, *req.ReservationID)
+		if err != nil {
+			return domain.Item{}, nil, err
+		}
+		if res.ItemID != itemID {
+			return domain.Item{}, nil, repo.ErrNotFound
+		}
+		if res.Status != domain.ReservationActive {
+			return domain.Item{}, nil, ErrReservationClosed
+		}
+		req.Qty = res.Qty
+		if req.Serials, err = s.consumedSerials(ctx, tx, res.ID, req.Serials); err != nil {
+			return domain.Item{}, nil, err
+		}
+		if req.Reference == nil {
+			ref := fmt.Sprintf("reservation:%d", res.ID)
+			req.Reference = &ref
+		}
+		if _, err := s.reserve.Close(ctx, tx, res.ID, domain.ReservationConsumed, actor); err != nil {
+			return domain.Item{}, nil, err
+		}
+	} else if req.Kind == domain.MovementIssue {
+		if err := checkReserved(ctx, tx, s.reserve, cur, cur.Qty-req.Qty); err != nil {
+			return domain.Item{}, nil, err
+		}
+	} else if req.Kind == domain.MovementAdjust {
+		if err := checkReserved(ctx, tx, s.reserve, cur, cur.Qty+req.Qty); err != nil {
+			return domain.Item{}, nil, err
+		}
+	}
+	// adjusts don't name lots, so they may only take stock outside of them
+	if req.Kind == domain.MovementAdjust {
+		if err := checkLots(ctx, tx, s.lots, cur, cur.Qty+req.Qty); err != nil {
+			return domain.Item{}, nil, err
+		}
+	}
+	if err := checkTracking(cur, req); err != nil {
+		return domain.Item{}, nil, err
+	}
+
+	from := req.Location
+	if from == nil {
+		from = cur.Location
+	}
+	fromID, err := bookableLocation(ctx, tx, s.locations, from)
+	if err != nil {
+		return domain.Item{}, nil, err
+	}
+
+	base := domain.Movement{
+		ItemID:    itemID,
+		Kind:      req.Kind,
+		Location:  from,
+		Reason:    req.Reason,
+		Reference: req.Reference,
+		Actor:     &actor,
+		ActorRole: &role,
+	}
+
+	var lines []domain.Movement
+	var toID *int64
+	switch req.Kind {
+	case domain.MovementReceive:
+		base.QtyDelta = req.Qty
+		if req.Lot != nil {
+			if base.CorrelationID, err = lotCorrelation(ctx, tx); err != nil {
+				return domain.Item{}, nil, err
+			}
+			lot, err := s.receiveLot(ctx, tx, itemID, *req.Lot, req.Qty)
+			if err != nil {
+				return domain.Item{}, nil, err
+			}
+			base.LotID, base.LotNumber = &lot.ID, &lot.Number
+		}
+		lines = append(lines, base)
+	case domain.MovementIssue:
+		if lines, err = s.issueLots(ctx, tx, cur, base, req); err != nil {
+			return domain.Item{}, nil, err
+		}
+	case domain.MovementAdjust:
+		base.QtyDelta = req.Qty
+		lines = append(lines, base)
+	case domain.MovementTransfer:
+		toID, err = bookableLocation(ctx, tx, s.locations, req.ToLocation)
+		if err != nil {
+			return domain.Item{}, nil, err
+		}
+		if toID == nil || (fromID != nil && *fromID == *toID) {
+			return domain.Item{}, nil, ErrSameLocation
+		}
+		left, err := s.stock.Add(ctx, tx, itemID, fromID, -req.Qty)
+		if err != nil {
+			return domain.Item{}, nil, err
+		}
+		if left < 0 {
+			return domain.Item{}, nil, ErrInsufficientStock
+		}
+		if _, err := s.stock.Add(ctx, tx, itemID, toID, req.Qty); err != nil {
+			return domain.Item{}, nil, err
+		}
+
+		corr := newCorrelationID()
+		if err := repo.SetCorrelationID(ctx, tx, corr); err != nil {
+			return domain.Item{}, nil, err
+		}
+		out, in := base, base
+		out.QtyDelta, out.CorrelationID = -req.Qty, &corr
+		in.QtyDelta, in.CorrelationID, in.Location = req.Qty, &corr, req.ToLocation
+		lines = append(lines, out, in)
+	}
+
+	if req.Kind != domain.MovementTransfer {
+		delta := 0
+		for _, l := range lines {
+			delta += l.QtyDelta
+		}
+		left, err := s.stock.Add(ctx, tx, itemID, fromID, delta)
+		if err != nil {
+			return domain.Item{}, nil, err
+		}
+		if left < 0 && role != domain.RoleAdmin.String() {
+			return domain.Item{}, nil, ErrInsufficientStock
+		}
+	}
+
+	if len(req.Serials) > 0 {
+		if err := s.moveSerials(ctx, tx, itemID, req, fromID, toID); err != nil {
+			return domain.Item{}, nil, err
+		}
+	}
+
+	upd := domain.ItemUpdate{SKU: cur.SKU, Name: cur.Name, Qty: cur.Qty}
+	for _, l := range lines {
+		upd.Qty += l.QtyDelta
+	}
+	if upd.Location, err = s.stock.PrimaryLocation(ctx, tx, itemID); err != nil {
+		return domain.Item{}, nil, err
+	}
+
+	it, err := s.items.Update(ctx, tx, itemID, upd)
+	if err != nil {
+		return domain.Item{}, nil, err
+	}
+
+	booked := make([]domain.Movement, 0, len(lines))
+	for _, l := range lines {
+		l.QtyAfter = it.Qty
+		m, err := s.repo.Insert(ctx, tx, l)
+		if err != nil {
+			return domain.Item{}, nil, err
+		}
+		booked = append(booked, m)
+	}
+
+	if err := queueItemEvents(ctx, tx, s.hooks, actor, &cur, &it); err != nil {
+		return domain.Item{}, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Item{}, nil, err
+	}
+	s.low.Changed(itemID)
+	return it, booked, nil
+}
+
+// checkTracking makes sure the request names what the tracking mode of the
+// item needs.
+func checkTracking(it domain.Item, req domain.MovementRequest) error {
+	if it.Tracking != domain.TrackingSerial {
+		if len(req.Serials) > 0 {
+			return ErrNotSerialized
+		}
+		if it.Tracking == domain.TrackingLot && req.Kind == domain.MovementReceive && req.Lot == nil {
+			return ErrLotRequired
+		}
+		return nil
+	}
+	if len(req.Serials) != max(req.Qty, -req.Qty) {
+		return ErrSerialsRequired
+	}
+	return nil
+}
+
+// moveSerials books the units named by a movement of a serialized item.
+// Received units come in at from; a shipped unit that comes back becomes
+// returned. Issued units are shipped, units taken out by an adjustment are
+// scrapped and transferred ones move to to. Outgoing units must be on hand
+// at from; reserved ones only move between locations until their own
+// reservation is consumed.
+func (s *MovementsService) moveSerials(ctx context.Context, tx pgx.Tx, itemID int64, req domain.MovementRequest, from, to *int64) error {
+	incoming := req.Kind == domain.MovementReceive || (req.Kind == domain.MovementAdjust && req.Qty > 0)
+
+	for _, sn := range req.Serials {
+		cur, err := s.serials.GetForUpdate(ctx, tx, sn)
+		if errors.Is(err, repo.ErrNotFound) {
+			if !incoming {
+				return &SerialError{Serial: sn, Err: ErrUnknownSerial}
+			}
+			if _, err := s.serials.Create(ctx, tx, itemID, sn, domain.SerialInStock, from); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if cur.ItemID != itemID {
+			return &SerialError{Serial: sn, Err: ErrSerialState}
+		}
+
+		status, loc := cur.Status, to
+		switch {
+		case incoming:
+			if cur.Status != domain.SerialShipped {
+				return &SerialError{Serial: sn, Err: ErrSerialState}
+			}
+			status, loc = domain.SerialReturned, from
+		case !cur.Status.OnHand() || !equalID(cur.LocationID, from):
+			return &SerialError{Serial: sn, Err: ErrSerialState}
+		case cur.Status == domain.SerialReserved && req.Kind != domain.MovementTransfer:
+			// a consumed reservation is closed by now, so its own units
+			// read as in stock; units held by any other one stay put
+			return &SerialError{Serial: sn, Err: ErrSerialState}
+		case req.Kind == domain.MovementIssue:
+			status, loc = domain.SerialShipped, nil
+		case req.Kind == domain.MovementAdjust:
+			status, loc = domain.SerialScrapped, nil
+		}
+
+		if _, err := s.serials.Update(ctx, tx, cur.ID, status, loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumedSerials returns the units to ship when the reservation is
+// consumed: the ones it holds unless the request names them, in which case
+// it must name all of them.
+func (s *MovementsService) consumedSerials(ctx context.Context, tx pgx.Tx, reservationID int64, named []string) ([]string, error) {
+	held, err := s.serials.ListReserved(ctx, tx, reservationID)
+	if err != nil || len(held) == 0 {
+		return named, err
+	}
+	if len(named) == 0 {
+		return held, nil
+	}
+	for _, sn := range held {
+		if !slices.Contains(named, sn) {
+			return nil, &SerialError{Serial: sn, Err: ErrSerialNotNamed}
+		}
+	}
+	return named, nil
+}
+
+func equalID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// receiveLot books qty into the lot, creating it on its first receive.
+func (s *MovementsService) receiveLot(ctx context.Context, tx pgx.Tx, itemID int64, in domain.LotInput, qty int) (domain.Lot, error) {
+	lot, err := s.lots.GetByNumberForUpdate(ctx, tx, itemID, in.Number)
+	if errors.Is(err, repo.ErrNotFound) {
+		return s.lots.Create(ctx, tx, itemID, in, qty)
+	}
+	if err != nil {
+		return domain.Lot{}, err
+	}
+	if !sameDay(in.ManufacturedOn, lot.ManufacturedOn) || !sameDay(in.ExpiresOn, lot.ExpiresOn) ||
+		(in.Supplier != nil && !equalOptional(in.Supplier, lot.Supplier)) {
+		return domain.Lot{}, ErrLotMismatch
+	}
+	return s.lots.Add(ctx, tx, lot.ID, qty)
+}
+
+// sameDay reports whether the requested date d matches the stored one; an
+// unset request matches anything.
+func sameDay(d, stored *time.Time) bool {
+	if d == nil {
+		return true
+	}
+	return stored != nil && d.Format(time.DateOnly) == stored.Format(time.DateOnly)
+}
+
+// issueLots splits an issue into one ledger line per lot it takes from. An
+// explicit lot must cover the whole qty. Otherwise unexpired lots are drawn
+// first-expired-first-out and the rest may only come from stock held
+// outside any lot.
+func (s *MovementsService) issueLots(ctx context.Context, tx pgx.Tx, cur domain.Item, base domain.Movement, req domain.MovementRequest) ([]domain.Movement, error) {
+	base.QtyDelta = -req.Qty
+
+	inLots, err := s.lots.Total(ctx, tx, cur.ID)
+	if err != nil {
+		return nil, err
+	}
+	if req.LotNumber == nil && inLots == 0 {
+		return []domain.Movement{base}, nil
+	}
+	if base.CorrelationID, err = lotCorrelation(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if req.LotNumber != nil {
+		lot, err := s.lots.GetByNumberForUpdate(ctx, tx, cur.ID, *req.LotNumber)
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil, ErrUnknownLot
+		}
+		if err != nil {
+			return nil, err
+		}
+		if lot.Qty < req.Qty {
+			return nil, ErrInsufficientStock
+		}
+		if _, err := s.lots.Add(ctx, tx, lot.ID, -req.Qty); err != nil {
+			return nil, err
+		}
+		base.LotID, base.LotNumber = &lot.ID, &lot.Number
+		return []domain.Movement{base}, nil
+	}
+
+	lots, err := s.lots.ListForIssue(ctx, tx, cur.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []domain.Movement
+	need := req.Qty
+	for _, lot := range lots {
+		if need == 0 {
+			break
+		}
+		take := min(need, lot.Qty)
+		if _, err := s.lots.Add(ctx, tx, lot.ID, -take); err != nil {
+			return nil, err
+		}
+		l := base
+		l.QtyDelta, l.LotID, l.LotNumber = -take, &lot.ID, &lot.Number
+		lines = append(lines, l)
+		need -= take
+	}
+	if need > 0 {
+		if need > cur.Qty-inLots {
+			return nil, ErrInsufficientStock
+		}
+		l := base
+		l.QtyDelta = -need
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// lotCorrelation ties the lot history rows, the item history row and the
+// ledger lines of one lot movement together.
+func lotCorrelation(ctx context.Context, tx pgx.Tx) (*string, error) {
+	corr := newCorrelationID()
+	if err := repo.SetCorrelationID(ctx, tx, corr); err != nil {
+		return nil, err
+	}
+	return &corr, nil
+}
+
+// recordQtyChange keeps the ledger in sync with writes that set qty
+// directly (create, PUT, PATCH).
+func recordQtyChange(ctx context.Context, tx pgx.Tx, movements *repo.MovementsRepo, actor, role string, it domain.Item, oldQty int, kind domain.MovementKind, reason string) error {
+	delta := it.Qty - oldQty
+	if delta == 0 {
+		return nil
+	}
+	_, err := movements.Insert(ctx, tx, domain.Movement{
+		ItemID:    it.ID,
+		Kind:      kind,
+		QtyDelta:  delta,
+		QtyAfter:  it.Qty,
+		Location:  it.Location,
+		Reason:    &reason,
+		Actor:     &actor,
+		ActorRole: &role,
+	})
+	return err
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/internal/domain"
+)
+
+const lowStockEvent = "item.low_stock"
+
+func alertScope(a domain.LowStockLine) string {
+	if a.Location != nil {
+		return *a.Location
+	}
+	return "all locations"
+}
+
+// LogNotifier writes alerts to the service log.
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(_ context.Context, a domain.LowStockLine) error {
+	n.logger.Warn("low stock",
+		"item_id", a.ItemID, "sku", a.SKU, "location", alertScope(a), "qty", a.Qty,
+		"min_qty", a.MinQty, "reorder_point", a.ReorderPoint.ReorderPoint, "severity", a.Severity,
+		"suggested_qty", a.SuggestedQty)
+	return nil
+}
+
+// WebhookNotifier POSTs alerts as JSON, signed like the item webhooks when
+// a secret is set.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type lowStockPayload struct {
+	ID        string              `json:"id"`
+	Type      string              `json:"type"`
+	CreatedAt time.Time           `json:"created_at"`
+	Data      domain.LowStockLine `json:"data"`
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a domain.LowStockLine) error {
+	id := newCorrelationID()
+	body, err := json.Marshal(lowStockPayload{ID: id, Type: lowStockEvent, CreatedAt: time.Now().UTC(), Data: a})
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "warehouse-webhooks/1")
+	req.Header.Set("X-Webhook-Id", id)
+	req.Header.Set("X-Webhook-Event", lowStockEvent)
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	if n.secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(n.secret, ts, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("receiver answered " + resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier mails alerts. STARTTLS is used when the server offers it and
+// credentials are only sent when set, so a plain local SMTP stub works too.
+type SMTPNotifier struct {
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+	timeout  time.Duration
+}
+
+func NewSMTPNotifier(addr, from string, to []string, username, password string) *SMTPNotifier {
+	return &SMTPNotifier{addr: addr, from: from, to: to, username: username, password: password, timeout: 30 * time.Second}
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, a domain.LowStockLine) error {
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return err
+	}
+
+	d := net.Dialer{Timeout: n.timeout}
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context; the deadline bounds the whole exchange
+	_ = conn.SetDeadline(time.Now().Add(n.timeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(a)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (n *SMTPNotifier) message(a domain.LowStockLine) []byte {
+	subject := fmt.Sprintf("Low stock: %s at %s", a.SKU, alertScope(a))
+
+	var b strings.Builder
+	b.WriteString("From: " + n.from + "\r\n")
+	b.WriteString("To: " + strings.Join(n.to, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s (%s) at %s is low.\r\n\r\n", a.SKU, a.Name, alertScope(a))
+	fmt.Fprintf(&b, "Qty:           %d\r\n", a.Qty)
+	fmt.Fprintf(&b, "Min qty:       %d\r\n", a.MinQty)
+	fmt.Fprintf(&b, "Reorder point: %d\r\n", a.ReorderPoint.ReorderPoint)
+	if a.MaxQty != nil {
+		fmt.Fprintf(&b, "Max qty:       %d\r\n", *a.MaxQty)
+	}
+	fmt.Fprintf(&b, "Severity:      %s\r\n", a.Severity)
+	fmt.Fprintf(&b, "Suggested qty: %d\r\n", a.SuggestedQty)
+	return []byte(b.String())
+}
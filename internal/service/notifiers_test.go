@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"warehouse/internal/domain"
+)
+
+func lowStockAlert() domain.LowStockLine {
+	loc := "A-01"
+	return domain.LowStockLine{
+		ReorderPoint: domain.ReorderPoint{ID: 3, ItemID: 7, Location: &loc, MinQty: 5, ReorderPoint: 10},
+		SKU:          "SKU-7",
+		Name:         "Bolt",
+		Qty:          4,
+		Severity:     domain.LowStockCritical,
+		SuggestedQty: 6,
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookNotifier(srv.URL, "s3cret").Notify(context.Background(), lowStockAlert()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Header.Get("X-Webhook-Event") != lowStockEvent {
+		t.Fatalf("event header: %q", got.Header.Get("X-Webhook-Event"))
+	}
+	m := hmac.New(sha256.New, []byte("s3cret"))
+	m.Write([]byte(got.Header.Get("X-Webhook-Timestamp") + "." + string(body)))
+	if sig := got.Header.Get("X-Webhook-Signature"); sig != "sha256="+hex.EncodeToString(m.Sum(nil)) {
+		t.Fatalf("signature: %q", sig)
+	}
+
+	var p lowStockPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != lowStockEvent || p.ID != got.Header.Get("X-Webhook-Id") || p.Data.SKU != "SKU-7" || p.Data.Qty != 4 {
+		t.Fatalf("payload: %s", body)
+	}
+}
+
+func TestWebhookNotifierFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookNotifier(srv.URL, "").Notify(context.Background(), lowStockAlert()); err == nil {
+		t.Fatal("want an error for a non-2xx answer")
+	}
+}
+
+// smtpSession is what the fake server below saw.
+type smtpSession struct {
+	from string
+	rcpt []string
+	data string
+}
+
+// serveSMTP answers one plain SMTP session on l: no STARTTLS, no AUTH.
+func serveSMTP(l net.Listener, done chan<- smtpSession) {
+	var s smtpSession
+	defer func() { done <- s }()
+
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	done := make(chan smtpSession, 1)
+	go serveSMTP(l, done)
+
+	n := NewSMTPNotifier(l.Addr().String(), "stock@example.com", []string{"a@example.com", "b@example.com"}, "", "")
+	if err := n.Notify(context.Background(), lowStockAlert()); err != nil {
+		t.Fatal(err)
+	}
+	s := <-done
+
+	if s.from != "stock@example.com" {
+		t.Fatalf("MAIL FROM: %q", s.from)
+	}
+	if strings.Join(s.rcpt, ",") != "a@example.com,b@example.com" {
+		t.Fatalf("RCPT TO: %v", s.rcpt)
+	}
+	head, body, ok := strings.Cut(s.data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator in %q", s.data)
+	}
+	if !strings.Contains(head, "Subject: Low stock: SKU-7 at A-01\r\n") {
+		t.Fatalf("headers: %q", head)
+	}
+	if !strings.Contains(head, "To: a@example.com, b@example.com\r\n") {
+		t.Fatalf("headers: %q", head)
+	}
+	for _, want := range []string{"SKU-7 (Bolt) at A-01 is low.", "Qty:           4", "Severity:      critical", "Suggested qty: 6"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("body lacks %q: %q", want, body)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var ErrSerialTransition = errors.New("status can only change from returned to in_stock; units are reserved through reservations")
+
+type SerialsService struct {
+	db      *repo.DB
+	repo    *repo.SerialsRepo
+	items   *repo.ItemsRepo
+	history *repo.HistoryRepo
+}
+
+func NewSerialsService(db *repo.DB, r *repo.SerialsRepo, items *repo.ItemsRepo, history *repo.HistoryRepo) *SerialsService {
+	return &SerialsService{db: db, repo: r, items: items, history: history}
+}
+
+func (s *SerialsService) ListByItem(ctx context.Context, itemID int64, status *domain.SerialStatus) ([]domain.Serial, error) {
+	return s.repo.ListByItem(ctx, itemID, status)
+}
+
+// Lifecycle returns the serial with every change recorded for it.
+func (s *SerialsService) Lifecycle(ctx context.Context, serial string) (domain.SerialLifecycle, error) {
+	sn, err := s.repo.Get(ctx, serial)
+	if err != nil {
+		return domain.SerialLifecycle{}, err
+	}
+	it, err := s.items.GetWithDeleted(ctx, sn.ItemID)
+	if err != nil {
+		return domain.SerialLifecycle{}, err
+	}
+	entries, err := s.history.ListBySerial(ctx, serial)
+	if err != nil {
+		return domain.SerialLifecycle{}, err
+	}
+	return domain.SerialLifecycle{Serial: sn, SKU: it.SKU, History: entries}, nil
+}
+
+// SetStatus puts a returned unit back in stock. Units leave or enter the
+// warehouse only through movements, which keep the item qty in step, and
+// are reserved only by the reservation that holds them.
+func (s *SerialsService) SetStatus(ctx context.Context, actor string, role string, serial string, status domain.SerialStatus) (domain.Serial, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Serial{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Serial{}, err
+	}
+
+	cur, err := s.repo.GetForUpdate(ctx, tx, serial)
+	if err != nil {
+		return domain.Serial{}, err
+	}
+	if cur.Status == status {
+		return cur, nil
+	}
+	if cur.Status != domain.SerialReturned || status != domain.SerialInStock {
+		return domain.Serial{}, ErrSerialTransition
+	}
+
+	sn, err := s.repo.Update(ctx, tx, cur.ID, status, cur.LocationID)
+	if err != nil {
+		return domain.Serial{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Serial{}, err
+	}
+	return sn, nil
+}
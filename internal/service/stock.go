@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrUnknownLocation  = errors.New("unknown location")
+	ErrMultiLocation    = errors.New("item is stocked at several locations, change its stock per location with movements")
+	ErrReservedLocation = errors.New(domain.TransitLocation + " holds goods in transit and only transfers book stock there")
+)
+
+// resolveLocation looks up an existing location by code; nil stays nil and
+// means stock that is not put away anywhere.
+func resolveLocation(ctx context.Context, tx pgx.Tx, locations *repo.LocationsRepo, code *string) (*int64, error) {
+	if code == nil {
+		return nil, nil
+	}
+	l, err := locations.GetByCode(ctx, tx, *code)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil, ErrUnknownLocation
+		}
+		return nil, err
+	}
+	return &l.ID, nil
+}
+
+// bookableLocation is resolveLocation for the ends of a movement or a
+// transfer, which may not be the transit bin.
+func bookableLocation(ctx context.Context, tx pgx.Tx, locations *repo.LocationsRepo, code *string) (*int64, error) {
+	if isTransit(code) {
+		return nil, ErrReservedLocation
+	}
+	return resolveLocation(ctx, tx, locations, code)
+}
+
+func isTransit(code *string) bool {
+	return code != nil && strings.EqualFold(strings.TrimSpace(*code), domain.TransitLocation)
+}
+
+// setLegacyStock maps the single qty/location pair of the item API onto
+// stock levels and returns the derived primary location. A qty change at
+// the current location is applied to that level only; moving the item to
+// another location moves all of its stock there. Neither may touch stock
+// at other locations: moving an item stocked at several locations, or
+// taking more than its primary location holds, fails with ErrMultiLocation.
+func setLegacyStock(ctx context.Context, tx pgx.Tx, stock *repo.StockRepo, locations *repo.LocationsRepo, cur domain.Item, in domain.ItemUpdate) (*string, error) {
+	sameLoc := equalOptional(cur.Location, in.Location)
+	if sameLoc && cur.Qty == in.Qty {
+		return cur.Location, nil
+	}
+	if isTransit(in.Location) {
+		return nil, ErrReservedLocation
+	}
+
+	var locID *int64
+	if in.Location != nil {
+		l, err := locations.EnsureBin(ctx, tx, *in.Location)
+		if err != nil {
+			return nil, err
+		}
+		locID = &l.ID
+	}
+
+	if sameLoc {
+		left, err := stock.Add(ctx, tx, cur.ID, locID, in.Qty-cur.Qty)
+		if err != nil {
+			return nil, err
+		}
+		if left < 0 {
+			return nil, ErrMultiLocation
+		}
+		return stock.PrimaryLocation(ctx, tx, cur.ID)
+	}
+
+	n, err := stock.CountLocations(ctx, tx, cur.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n > 1 {
+		return nil, ErrMultiLocation
+	}
+	if err := stock.Collapse(ctx, tx, cur.ID, locID, in.Qty); err != nil {
+		return nil, err
+	}
+	return stock.PrimaryLocation(ctx, tx, cur.ID)
+}
+
+func equalOptional(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
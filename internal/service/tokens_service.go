@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/auth"
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+type TokensService struct {
+	db    *repo.DB
+	repo  *repo.TokensRepo
+	users *repo.UsersRepo
+	jwt   *auth.Manager
+
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokensService(db *repo.DB, r *repo.TokensRepo, users *repo.UsersRepo, jwtMgr *auth.Manager, accessTTL, refreshTTL time.Duration) *TokensService {
+	return &TokensService{
+		db:         db,
+		repo:       r,
+		users:      users,
+		jwt:        jwtMgr,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue starts a new refresh token family for a freshly authenticated user.
+func (s *TokensService) Issue(ctx context.Context, u domain.User) (domain.TokenPair, error) {
+	familyID, err := auth.RandomToken(16)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	pair, err := s.issue(ctx, tx, u, familyID)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already
+// rotated or revoked revokes its whole family, since it means the token
+// leaked to someone else.
+func (s *TokensService) Refresh(ctx context.Context, refreshToken string) (domain.TokenPair, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	t, err := s.repo.GetByHashForUpdate(ctx, tx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return domain.TokenPair{}, ErrInvalidRefreshToken
+		}
+		return domain.TokenPair{}, err
+	}
+
+	if t.UsedAt != nil || t.RevokedAt != nil {
+		if err := s.revokeFamily(ctx, tx, t.FamilyID); err != nil {
+			return domain.TokenPair{}, err
+		}
+		return domain.TokenPair{}, ErrRefreshTokenReused
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return domain.TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	u, err := s.users.GetByUsername(ctx, t.Username)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		return domain.TokenPair{}, err
+	}
+	if err != nil || u.Disabled {
+		if err := s.revokeFamily(ctx, tx, t.FamilyID); err != nil {
+			return domain.TokenPair{}, err
+		}
+		return domain.TokenPair{}, ErrUserDisabled
+	}
+
+	if err := s.repo.MarkUsed(ctx, tx, t.ID); err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	pair, err := s.issue(ctx, tx, u, t.FamilyID)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// Logout revokes the current access token and, if given, the refresh token
+// family it belongs to.
+func (s *TokensService) Logout(ctx context.Context, username, jti string, accessExp time.Time, refreshToken string) error {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if jti != "" {
+		if err := s.repo.RevokeJTI(ctx, tx, jti, accessExp); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		t, err := s.repo.GetByHashForUpdate(ctx, tx, auth.HashToken(refreshToken))
+		if err != nil && !errors.Is(err, repo.ErrNotFound) {
+			return err
+		}
+		if err == nil && t.Username == username {
+			if err := s.repo.RevokeFamily(ctx, tx, t.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RevokeUser kills every session of the user: all refresh token families
+// and the access tokens issued with them.
+func (s *TokensService) RevokeUser(ctx context.Context, username string) error {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := s.repo.RevokeUser(ctx, tx, username); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RunPurge drops expired entries from the access token denylist every
+// interval until ctx is done.
+func (s *TokensService) RunPurge(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		n, err := s.repo.PurgeRevoked(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("revoked tokens purge failed", "err", err)
+		}
+		if n > 0 {
+			logger.Info("expired revoked tokens purged", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *TokensService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.repo.IsRevoked(ctx, jti)
+}
+
+func (s *TokensService) issue(ctx context.Context, tx pgx.Tx, u domain.User, familyID string) (domain.TokenPair, error) {
+	access, claims, err := s.jwt.Generate(u.Username, u.Role.String(), s.accessTTL)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	refresh, err := auth.RandomToken(32)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	if err := s.repo.Insert(ctx, tx, auth.HashToken(refresh), domain.RefreshToken{
+		FamilyID:  familyID,
+		Username:  u.Username,
+		AccessJTI: claims.ID,
+		AccessExp: claims.ExpiresAt.Time,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}); err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	return domain.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+	}, nil
+}
+
+// revokeFamily commits the revocation on its own so it sticks even though
+// the caller returns an error afterwards.
+func (s *TokensService) revokeFamily(ctx context.Context, tx pgx.Tx, familyID string) error {
+	if err := s.repo.RevokeFamily(ctx, tx, familyID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrInvalidTransition = errors.New("transfer is not in a state that allows this operation")
+	ErrTransferNotFound  = errors.New("transfer not found")
+)
+
+type TransfersService struct {
+	db        *repo.DB
+	repo      *repo.TransfersRepo
+	items     *repo.ItemsRepo
+	movements *repo.MovementsRepo
+	stock     *repo.StockRepo
+	locations *repo.LocationsRepo
+	hooks     *repo.WebhooksRepo
+
+	// told about committed stock changes, may be nil
+	low *LowStockService
+}
+
+func NewTransfersService(db *repo.DB, r *repo.TransfersRepo, items *repo.ItemsRepo, movements *repo.MovementsRepo, stock *repo.StockRepo, locations *repo.LocationsRepo, hooks *repo.WebhooksRepo, low *LowStockService) *TransfersService {
+	return &TransfersService{db: db, repo: r, items: items, movements: movements, stock: stock, locations: locations, hooks: hooks, low: low}
+}
+
+func (s *TransfersService) List(ctx context.Context, status *domain.TransferStatus) ([]domain.Transfer, error) {
+	return s.repo.List(ctx, status)
+}
+
+func (s *TransfersService) Get(ctx context.Context, id int64) (domain.Transfer, error) {
+	t, err := s.repo.Get(ctx, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		return domain.Transfer{}, ErrTransferNotFound
+	}
+	return t, err
+}
+
+// Create stores a draft transfer document. With receive set the goods are
+// booked from source to destination right away in the same transaction.
+func (s *TransfersService) Create(ctx context.Context, actor string, role string, in domain.TransferCreate, receive bool) (domain.Transfer, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Transfer{}, err
+	}
+
+	fromID, err := bookableLocation(ctx, tx, s.locations, &in.FromLocation)
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	toID, err := bookableLocation(ctx, tx, s.locations, &in.ToLocation)
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	if *fromID == *toID {
+		return domain.Transfer{}, ErrSameLocation
+	}
+
+	sort.Slice(in.Lines, func(i, j int) bool { return in.Lines[i].ItemID < in.Lines[j].ItemID })
+	for _, l := range in.Lines {
+		it, err := s.items.GetForUpdate(ctx, tx, l.ItemID)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				return domain.Transfer{}, fmt.Errorf("item %d: %w", l.ItemID, err)
+			}
+			return domain.Transfer{}, err
+		}
+		// transfer documents don't name units
+		if it.Tracking == domain.TrackingSerial {
+			return domain.Transfer{}, fmt.Errorf("item %s: %w", it.SKU, ErrSerialTracked)
+		}
+	}
+
+	id, err := s.repo.Create(ctx, tx, *fromID, *toID, in.Reference, newCorrelationID(), actor, in.Lines)
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+
+	if receive {
+		t, err := s.repo.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return domain.Transfer{}, err
+		}
+		if err := s.book(ctx, tx, actor, role, t, fromID, t.FromLocation, toID, t.ToLocation); err != nil {
+			return domain.Transfer{}, err
+		}
+		if err := s.repo.SetStatus(ctx, tx, id, domain.TransferReceived); err != nil {
+			return domain.Transfer{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Transfer{}, err
+	}
+	if receive {
+		s.low.Changed(lineItems(in.Lines)...)
+	}
+	return s.repo.Get(ctx, id)
+}
+
+// Dispatch moves the goods from the source into transit.
+func (s *TransfersService) Dispatch(ctx context.Context, actor string, role string, id int64) (domain.Transfer, error) {
+	return s.transition(ctx, actor, role, id, func(tx pgx.Tx, t domain.Transfer) (domain.TransferStatus, error) {
+		if t.Status != domain.TransferDraft {
+			return "", ErrInvalidTransition
+		}
+		fromID, transitID, err := s.endpoints(ctx, tx, t.FromLocation)
+		if err != nil {
+			return "", err
+		}
+		return domain.TransferInTransit, s.book(ctx, tx, actor, role, t, fromID, t.FromLocation, transitID, domain.TransitLocation)
+	})
+}
+
+// Receive books the goods at the destination, from transit or, for a draft
+// that was never dispatched, directly from the source.
+func (s *TransfersService) Receive(ctx context.Context, actor string, role string, id int64) (domain.Transfer, error) {
+	return s.transition(ctx, actor, role, id, func(tx pgx.Tx, t domain.Transfer) (domain.TransferStatus, error) {
+		src := t.FromLocation
+		switch t.Status {
+		case domain.TransferDraft:
+		case domain.TransferInTransit:
+			src = domain.TransitLocation
+		default:
+			return "", ErrInvalidTransition
+		}
+		srcID, _, err := s.endpoints(ctx, tx, src)
+		if err != nil {
+			return "", err
+		}
+		toID, err := bookableLocation(ctx, tx, s.locations, &t.ToLocation)
+		if err != nil {
+			return "", err
+		}
+		return domain.TransferReceived, s.book(ctx, tx, actor, role, t, srcID, src, toID, t.ToLocation)
+	})
+}
+
+// Cancel drops a draft or returns dispatched goods to the source.
+func (s *TransfersService) Cancel(ctx context.Context, actor string, role string, id int64) (domain.Transfer, error) {
+	return s.transition(ctx, actor, role, id, func(tx pgx.Tx, t domain.Transfer) (domain.TransferStatus, error) {
+		switch t.Status {
+		case domain.TransferDraft:
+			return domain.TransferCancelled, nil
+		case domain.TransferInTransit:
+			fromID, transitID, err := s.endpoints(ctx, tx, t.FromLocation)
+			if err != nil {
+				return "", err
+			}
+			return domain.TransferCancelled, s.book(ctx, tx, actor, role, t, transitID, domain.TransitLocation, fromID, t.FromLocation)
+		default:
+			return "", ErrInvalidTransition
+		}
+	})
+}
+
+func (s *TransfersService) transition(ctx context.Context, actor, role string, id int64, step func(pgx.Tx, domain.Transfer) (domain.TransferStatus, error)) (domain.Transfer, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := repo.SetUserContext(ctx, tx, actor, role); err != nil {
+		return domain.Transfer{}, err
+	}
+
+	t, err := s.repo.GetForUpdate(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return domain.Transfer{}, ErrTransferNotFound
+		}
+		return domain.Transfer{}, err
+	}
+
+	next, err := step(tx, t)
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	if err := s.repo.SetStatus(ctx, tx, id, next); err != nil {
+		return domain.Transfer{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Transfer{}, err
+	}
+	s.low.Changed(lineItems(t.Lines)...)
+	return s.repo.Get(ctx, id)
+}
+
+func lineItems(lines []domain.TransferLine) []int64 {
+	ids := make([]int64, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.ItemID)
+	}
+	return ids
+}
+
+// endpoints resolves a location code together with the transit bin.
+func (s *TransfersService) endpoints(ctx context.Context, tx pgx.Tx, code string) (*int64, *int64, error) {
+	id, err := bookableLocation(ctx, tx, s.locations, &code)
+	if err != nil {
+		return nil, nil, err
+	}
+	transit, err := s.locations.EnsureBin(ctx, tx, domain.TransitLocation)
+	if err != nil {
+		return nil, nil, err
+	}
+	return id, &transit.ID, nil
+}
+
+// book moves every line of the transfer from one location to another. Both
+// sides of each line are written in the caller's transaction and share the
+// transfer's correlation id in the ledger and in items_history; webhook
+// events go into the same transaction's outbox.
+func (s *TransfersService) book(ctx context.Context, tx pgx.Tx, actor, role string, t domain.Transfer, fromID *int64, fromCode string, toID *int64, toCode string) error {
+	if err := repo.SetCorrelationID(ctx, tx, t.CorrelationID); err != nil {
+		return err
+	}
+
+	lines := append([]domain.TransferLine(nil), t.Lines...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ItemID < lines[j].ItemID })
+
+	reference := fmt.Sprintf("transfer:%d", t.ID)
+	corr := t.CorrelationID
+
+	for _, l := range lines {
+		cur, err := s.items.GetForUpdate(ctx, tx, l.ItemID)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", l.ItemID, err)
+		}
+
+		left, err := s.stock.Add(ctx, tx, l.ItemID, fromID, -l.Qty)
+		if err != nil {
+			return err
+		}
+		if left < 0 {
+			return fmt.Errorf("item %s at %s: %w", cur.SKU, fromCode, ErrInsufficientStock)
+		}
+		if _, err := s.stock.Add(ctx, tx, l.ItemID, toID, l.Qty); err != nil {
+			return err
+		}
+
+		loc, err := s.stock.PrimaryLocation(ctx, tx, l.ItemID)
+		if err != nil {
+			return err
+		}
+		it, err := s.items.Update(ctx, tx, l.ItemID, domain.ItemUpdate{SKU: cur.SKU, Name: cur.Name, Qty: cur.Qty, Location: loc})
+		if err != nil {
+			return err
+		}
+		if err := queueItemEvents(ctx, tx, s.hooks, actor, &cur, &it); err != nil {
+			return err
+		}
+
+		for _, side := range []struct {
+			code  string
+			delta int
+		}{{fromCode, -l.Qty}, {toCode, l.Qty}} {
+			code := side.code
+			if _, err := s.movements.Insert(ctx, tx, domain.Movement{
+				ItemID:        l.ItemID,
+				Kind:          domain.MovementTransfer,
+				QtyDelta:      side.delta,
+				QtyAfter:      it.Qty,
+				Location:      &code,
+				Reference:     &reference,
+				CorrelationID: &corr,
+				Actor:         &actor,
+				ActorRole:     &role,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUserDisabled       = errors.New("user is disabled")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+	ErrSelfLockout        = errors.New("admins can't disable or demote their own account")
+	ErrLastAdmin          = errors.New("the last active admin can't be disabled or demoted")
+)
+
+const minPasswordLen = 8
+
+// dummyHash is compared against when the username is unknown, so that
+// path costs as much as a wrong password.
+var dummyHash = []byte("$2a$10$Z5pUgNHLBwrskVIkRy4K5eE7NRTdINF5Fg9rCpN2BO.X96qVdTXWO")
+
+type UsersService struct {
+	db     *repo.DB
+	repo   *repo.UsersRepo
+	tokens *repo.TokensRepo
+}
+
+func NewUsersService(db *repo.DB, r *repo.UsersRepo, tokens *repo.TokensRepo) *UsersService {
+	return &UsersService{db: db, repo: r, tokens: tokens}
+}
+
+func (s *UsersService) Authenticate(ctx context.Context, username, password string) (domain.User, error) {
+	u, err := s.repo.GetByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return domain.User{}, ErrInvalidCredentials
+		}
+		return domain.User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return domain.User{}, ErrInvalidCredentials
+	}
+	if u.Disabled {
+		return domain.User{}, ErrUserDisabled
+	}
+	return u, nil
+}
+
+func (s *UsersService) List(ctx context.Context) ([]domain.User, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *UsersService) Get(ctx context.Context, id int64) (domain.User, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *UsersService) Create(ctx context.Context, in domain.UserCreate) (domain.User, error) {
+	if len(in.Password) < minPasswordLen {
+		return domain.User{}, ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return s.repo.Create(ctx, strings.TrimSpace(in.Username), string(hash), in.Role)
+}
+
+// SetRole changes the role of a user on behalf of actor and revokes their
+// sessions in the same transaction, since the old role is baked into issued
+// tokens. Admins can't demote themselves, and the last active admin can't
+// be demoted.
+func (s *UsersService) SetRole(ctx context.Context, actor string, id int64, role domain.Role) (domain.User, error) {
+	return s.change(ctx, actor, id, role != domain.RoleAdmin, func(tx pgx.Tx) (domain.User, error) {
+		u, err := s.repo.SetRole(ctx, tx, id, role)
+		if err != nil {
+			return domain.User{}, err
+		}
+		return u, s.tokens.RevokeUser(ctx, tx, u.Username)
+	})
+}
+
+// SetDisabled disables or enables a user on behalf of actor, with the same
+// guards as SetRole. Disabling revokes the user's sessions.
+func (s *UsersService) SetDisabled(ctx context.Context, actor string, id int64, disabled bool) (domain.User, error) {
+	return s.change(ctx, actor, id, disabled, func(tx pgx.Tx) (domain.User, error) {
+		u, err := s.repo.SetDisabled(ctx, tx, id, disabled)
+		if err != nil || !disabled {
+			return u, err
+		}
+		return u, s.tokens.RevokeUser(ctx, tx, u.Username)
+	})
+}
+
+// change applies fn to user id. When revokesAdmin is set and the user is an
+// active admin, the change would take admin rights away from them, which
+// is refused for the actor's own account and for the last admin. Active
+// admins are locked first so concurrent changes can't remove them all.
+func (s *UsersService) change(ctx context.Context, actor string, id int64, revokesAdmin bool, fn func(pgx.Tx) (domain.User, error)) (domain.User, error) {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return domain.User{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	admins, err := s.repo.LockActiveAdmins(ctx, tx)
+	if err != nil {
+		return domain.User{}, err
+	}
+	cur, err := s.repo.GetForUpdate(ctx, tx, id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if revokesAdmin && cur.Role == domain.RoleAdmin && !cur.Disabled {
+		if cur.Username == actor {
+			return domain.User{}, ErrSelfLockout
+		}
+		if admins <= 1 {
+			return domain.User{}, ErrLastAdmin
+		}
+	}
+
+	u, err := fn(tx)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+// Bootstrap creates the first admin account when the users table is empty.
+// It is a no-op once any user exists or when no credentials are configured.
+func (s *UsersService) Bootstrap(ctx context.Context, username, password string) (bool, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return false, nil
+	}
+
+	n, err := s.repo.Count(ctx)
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return false, nil
+	}
+
+	if _, err := s.Create(ctx, domain.UserCreate{
+		Username: username,
+		Password: password,
+		Role:     domain.RoleAdmin,
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"warehouse/internal/domain"
+	"warehouse/internal/repo"
+)
+
+var (
+	ErrInvalidWebhookURL  = errors.New("url must be an absolute http(s) url")
+	ErrInvalidWebhookType = errors.New("unknown event type")
+)
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it is
+	// dead lettered.
+	webhookMaxAttempts = 10
+	webhookBatch       = 50
+	webhookTimeout     = 10 * time.Second
+	// webhookLease outlasts sending a whole batch to receivers that all
+	// time out, so a claimed delivery is never handed out twice.
+	webhookLease = webhookBatch*webhookTimeout + time.Minute
+	webhookPoll  = 2 * time.Second
+)
+
+type WebhooksService struct {
+	repo   *repo.WebhooksRepo
+	client *http.Client
+}
+
+func NewWebhooksService(r *repo.WebhooksRepo) *WebhooksService {
+	return &WebhooksService{repo: r, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// List returns the subscriptions without their secrets.
+func (s *WebhooksService) List(ctx context.Context) ([]domain.Webhook, error) {
+	hooks, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+// Create registers a subscription. Without a secret one is generated; the
+// secret is only ever returned here.
+func (s *WebhooksService) Create(ctx context.Context, in domain.WebhookInput) (domain.Webhook, error) {
+	if err := validateWebhook(in); err != nil {
+		return domain.Webhook{}, err
+	}
+	if in.Secret == "" {
+		in.Secret = newCorrelationID() + newCorrelationID()
+	}
+	return s.repo.Create(ctx, in)
+}
+
+func (s *WebhooksService) Update(ctx context.Context, id int64, in domain.WebhookInput) (domain.Webhook, error) {
+	if err := validateWebhook(in); err != nil {
+		return domain.Webhook{}, err
+	}
+	w, err := s.repo.Update(ctx, id, in)
+	w.Secret = ""
+	return w, err
+}
+
+func (s *WebhooksService) Delete(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *WebhooksService) Deliveries(ctx context.Context, webhookID int64, status *domain.DeliveryStatus, limit int) ([]domain.WebhookDelivery, error) {
+	return s.repo.ListDeliveries(ctx, webhookID, status, limit)
+}
+
+func (s *WebhooksService) Retry(ctx context.Context, webhookID, deliveryID int64) error {
+	return s.repo.Retry(ctx, webhookID, deliveryID)
+}
+
+func validateWebhook(in domain.WebhookInput) error {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	if len(in.EventTypes) == 0 {
+		return ErrInvalidWebhookType
+	}
+	for _, t := range in.EventTypes {
+		if !slices.Contains(domain.WebhookEventTypes, t) {
+			return ErrInvalidWebhookType
+		}
+	}
+	return nil
+}
+
+// RunDispatcher sends due deliveries until ctx is done. Failed attempts are
+// retried with exponential backoff and dead lettered after
+// webhookMaxAttempts.
+func (s *WebhooksService) RunDispatcher(ctx context.Context, logger *slog.Logger) {
+	t := time.NewTicker(webhookPoll)
+	defer t.Stop()
+
+	for {
+		for {
+			n, err := s.dispatchDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("webhook dispatch failed", "err", err)
+			}
+			if err != nil || n < webhookBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *WebhooksService) dispatchDue(ctx context.Context) (int, error) {
+	due, err := s.repo.ClaimDue(ctx, webhookBatch, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		status, err := s.send(ctx, d)
+		if err == nil {
+			if err := s.repo.MarkDelivered(ctx, d.ID, status); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		var code *int
+		if status != 0 {
+			code = &status
+		}
+		if err := s.repo.MarkFailed(ctx, d.ID, code, err.Error(), webhookRetryAt(d.Attempts+1, time.Now())); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// webhookRetryAt is when a delivery that failed its attempt-th try is tried
+// again, nil once it is out of attempts and goes to the dead letters.
+func webhookRetryAt(attempt int, now time.Time) *time.Time {
+	if attempt >= webhookMaxAttempts {
+		return nil
+	}
+	at := now.Add(webhookBackoff(attempt))
+	return &at
+}
+
+// webhookBackoff doubles the delay from 10s per attempt up to 6h, with up
+// to 20% jitter so that retries of one outage don't arrive all at once.
+func webhookBackoff(attempt int) time.Duration {
+	d := 10 * time.Second << min(attempt-1, 11)
+	if d > 6*time.Hour {
+		d = 6 * time.Hour
+	}
+	return d + time.Duration(rand.Int63n(int64(d/5)+1))
+}
+
+// send POSTs the payload signed with the webhook secret:
+// X-Webhook-Signature is "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func (s *WebhooksService) send(ctx context.Context, d domain.OutboundDelivery) (int, error) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "warehouse-webhooks/1")
+	req.Header.Set("X-Webhook-Id", d.EventID)
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(d.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New("receiver answered " + resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook is the hex HMAC-SHA256 receivers recompute to check a
+// delivery.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(timestamp))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+type webhookPayload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor,omitempty"`
+	Data      webhookItemData `json:"data"`
+}
+
+type webhookItemData struct {
+	Item     domain.Item  `json:"item"`
+	Previous *domain.Item `json:"previous,omitempty"`
+	QtyDelta *int         `json:"qty_delta,omitempty"`
+}
+
+// queueItemEvents writes the webhook events describing the change from
+// before to after into the outbox of tx. before is nil for a new item,
+// after is nil for a deleted one.
+func queueItemEvents(ctx context.Context, tx pgx.Tx, hooks *repo.WebhooksRepo, actor string, before, after *domain.Item) error {
+	now := time.Now().UTC()
+	queue := func(typ string, data webhookItemData) error {
+		p := webhookPayload{ID: newCorrelationID(), Type: typ, CreatedAt: now, Actor: actor, Data: data}
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		return hooks.Enqueue(ctx, tx, p.ID, typ, data.Item.ID, b)
+	}
+
+	switch {
+	case before == nil && after != nil:
+		return queue(domain.EventItemCreated, webhookItemData{Item: *after})
+	case before != nil && after == nil:
+		return queue(domain.EventItemDeleted, webhookItemData{Item: *before})
+	case before == nil:
+		return nil
+	case before.DeletedAt != nil && after.DeletedAt == nil:
+		return queue(domain.EventItemRestored, webhookItemData{Item: *after})
+	}
+
+	if before.SKU != after.SKU || before.Name != after.Name || before.Qty != after.Qty || !equalOptional(before.Location, after.Location) {
+		if err := queue(domain.EventItemUpdated, webhookItemData{Item: *after, Previous: before}); err != nil {
+			return err
+		}
+	}
+	if before.Qty != after.Qty {
+		delta := after.Qty - before.Qty
+		return queue(domain.EventItemQtyChanged, webhookItemData{Item: *after, Previous: before, QtyDelta: &delta})
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"warehouse/internal/domain"
+)
+
+func TestWebhookSendSigns(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewWebhooksService(nil)
+	d := domain.OutboundDelivery{
+		URL:       srv.URL,
+		Secret:    "s3cret",
+		EventID:   "evt-1",
+		EventType: domain.EventItemUpdated,
+		Payload:   []byte(`{"id":"evt-1"}`),
+	}
+	status, err := s.send(context.Background(), d)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("send: status %d, err %v", status, err)
+	}
+
+	if string(body) != string(d.Payload) {
+		t.Fatalf("body: got %s", body)
+	}
+	if got.Header.Get("X-Webhook-Id") != "evt-1" || got.Header.Get("X-Webhook-Event") != domain.EventItemUpdated {
+		t.Fatalf("headers: %v", got.Header)
+	}
+	ts := got.Header.Get("X-Webhook-Timestamp")
+	m := hmac.New(sha256.New, []byte("s3cret"))
+	m.Write([]byte(ts + "." + string(d.Payload)))
+	want := "sha256=" + hex.EncodeToString(m.Sum(nil))
+	if sig := got.Header.Get("X-Webhook-Signature"); sig != want {
+		t.Fatalf("signature: got %s, want %s", sig, want)
+	}
+}
+
+func TestWebhookSendFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	s := NewWebhooksService(nil)
+	d := domain.OutboundDelivery{URL: srv.URL, Secret: "x", Payload: []byte(`{}`)}
+
+	status, err := s.send(context.Background(), d)
+	if err == nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("non-2xx: status %d, err %v", status, err)
+	}
+
+	srv.Close()
+	status, err = s.send(context.Background(), d)
+	if err == nil || status != 0 {
+		t.Fatalf("unreachable: status %d, err %v", status, err)
+	}
+}
+
+func TestWebhookRetryAt(t *testing.T) {
+	now := time.Now()
+	for attempt := 1; attempt < webhookMaxAttempts; attempt++ {
+		at := webhookRetryAt(attempt, now)
+		if at == nil {
+			t.Fatalf("attempt %d: dead lettered too early", attempt)
+		}
+		base := min(10*time.Second<<(attempt-1), 6*time.Hour)
+		if d := at.Sub(now); d < base || d > base+base/5 {
+			t.Fatalf("attempt %d: retry in %s, want %s plus up to 20%%", attempt, d, base)
+		}
+	}
+	if at := webhookRetryAt(webhookMaxAttempts, now); at != nil {
+		t.Fatalf("attempt %d: want dead letter, got retry at %s", webhookMaxAttempts, at)
+	}
+	if d := webhookBackoff(40); d < 6*time.Hour || d > 6*time.Hour+6*time.Hour/5 {
+		t.Fatalf("backoff is not capped: %s", d)
+	}
+}
@@ -0,0 +1,459 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoSheet     = errors.New("xlsx: workbook has no sheets")
+	ErrTooLarge    = errors.New("xlsx: workbook is too large")
+	ErrTooManyRows = errors.New("xlsx: sheet has more rows than allowed")
+)
+
+const (
+	// MaxRows and MaxColumns are the sheet limits of Excel.
+	MaxRows    = 1 << 20
+	MaxColumns = 1 << 14
+	// maxPartSize bounds the unpacked size of a sheet or the shared
+	// strings, maxMetaSize that of the workbook and its relationships, so
+	// a small upload can't expand into gigabytes.
+	maxPartSize = 256 << 20
+	maxMetaSize = 1 << 20
+	// maxDepth bounds element nesting, which the XML decoder keeps in
+	// memory; real sheets nest a handful of levels.
+	maxDepth = 32
+)
+
+// maxCells bounds the cell elements read from a sheet plus the empty cells
+// ReadRows pads gaps with, and the number of shared strings; a part may hold
+// at most elementsPerCell times as many elements of any kind. A variable so
+// tests can lower it.
+var maxCells = 1 << 22
+
+const elementsPerCell = 8
+
+// ReadRows returns the cell text of the first sheet of a workbook, one
+// slice per row. Missing cells and rows come back as empty strings so that
+// column positions are kept. A row past maxRows fails with ErrTooManyRows
+// (maxRows <= 0 means MaxRows), cells beyond the sheet limits are an error.
+// The sheet is read as a stream, so memory follows the rows returned rather
+// than the size of the XML.
+func ReadRows(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	if maxRows <= 0 || maxRows > MaxRows {
+		maxRows = MaxRows
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := sharedStrings(files)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := files[sheet]
+	if !ok {
+		return nil, ErrNoSheet
+	}
+	rc, err := open(f, maxPartSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	sr := sheetReader{tokens: tokens{d: xml.NewDecoder(rc)}, shared: shared}
+	return sr.rows(maxRows)
+}
+
+type sheetReader struct {
+	tokens
+	shared []string
+	// cells counts cell elements and padding against maxCells
+	cells int
+}
+
+func (s *sheetReader) rows(maxRows int) ([][]string, error) {
+	var out [][]string
+	for {
+		tok, err := s.next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		n := len(out) + 1
+		if v := attr(start, "r"); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.New("xlsx: bad row number " + v)
+			}
+			if i > 0 {
+				n = i
+			}
+		}
+		if n > MaxRows {
+			return nil, fmt.Errorf("xlsx: row %d is beyond the sheet limit of %d rows", n, MaxRows)
+		}
+		if n > maxRows {
+			return nil, ErrTooManyRows
+		}
+
+		cells, err := s.row()
+		if err != nil {
+			return nil, err
+		}
+		for len(out) < n {
+			out = append(out, nil)
+		}
+		out[n-1] = cells
+	}
+}
+
+// row reads the cells of the <row> just opened, up to its end tag.
+func (s *sheetReader) row() ([]string, error) {
+	var cells []string
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return cells, nil
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				if err := s.skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			ref := attr(t, "r")
+			col := len(cells)
+			if ref != "" {
+				if col, err = columnIndex(ref); err != nil {
+					return nil, err
+				}
+			}
+			if col >= MaxColumns {
+				return nil, errors.New("xlsx: cell " + ref + " is beyond the sheet limit of columns")
+			}
+			// every cell element counts, and so does the padding before it
+			if s.cells += max(col+1-len(cells), 1); s.cells > maxCells {
+				return nil, ErrTooLarge
+			}
+
+			v, text, err := s.cell()
+			if err != nil {
+				return nil, err
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+
+			switch attr(t, "t") {
+			case "s":
+				i, err := strconv.Atoi(strings.TrimSpace(v))
+				if err != nil || i < 0 || i >= len(s.shared) {
+					return nil, errors.New("xlsx: bad shared string index in " + ref)
+				}
+				cells[col] = s.shared[i]
+			case "inlineStr":
+				cells[col] = text
+			case "b":
+				cells[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[v]
+			default:
+				cells[col] = v
+			}
+		}
+	}
+}
+
+// cell reads the <c> just opened up to its end tag and returns the text of
+// its <v> and of its inline string.
+func (s *sheetReader) cell() (v, text string, err error) {
+	var vb, tb strings.Builder
+	// names of the open elements inside the cell, up to three levels
+	var path [3]string
+	depth := 0
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return "", "", unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth < len(path) {
+				path[depth] = t.Name.Local
+			}
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return vb.String(), tb.String(), nil
+			}
+			depth--
+		case xml.CharData:
+			switch {
+			case depth == 1 && path[0] == "v":
+				vb.Write(t)
+			case path[0] == "is" && isText(path[1:], depth-1):
+				tb.Write(t)
+			}
+		}
+	}
+}
+
+// isText reports whether the element path below a string item (<si> or
+// <is>) is its text: a plain <t> or the <t> of a rich text run. Phonetic
+// runs are left out.
+func isText(path []string, depth int) bool {
+	switch depth {
+	case 1:
+		return path[0] == "t"
+	case 2:
+		return path[0] == "r" && path[1] == "t"
+	}
+	return false
+}
+
+// tokens walks a part token by token, refusing nesting deeper than
+// maxDepth and more than maxCells*elementsPerCell elements.
+type tokens struct {
+	d        *xml.Decoder
+	depth    int
+	elements int
+}
+
+func (t *tokens) next() (xml.Token, error) {
+	tok, err := t.d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.(type) {
+	case xml.StartElement:
+		if t.depth++; t.depth > maxDepth {
+			return nil, ErrTooLarge
+		}
+		if t.elements++; t.elements > maxCells*elementsPerCell {
+			return nil, ErrTooLarge
+		}
+	case xml.EndElement:
+		t.depth--
+	}
+	return tok, nil
+}
+
+// skip consumes the element just opened up to its end tag.
+func (t *tokens) skip() error {
+	for depth := 0; ; {
+		tok, err := t.next()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// firstSheetPath resolves the part name of the first sheet through the
+// workbook relationships.
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeFile(files, "xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", ErrNoSheet
+	}
+
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeFile(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Rels {
+		if rel.ID != wb.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", ErrNoSheet
+}
+
+// sharedStrings reads the shared string table as a stream, one <si> at a
+// time.
+func sharedStrings(files map[string]*zip.File) ([]string, error) {
+	f, ok := files["xl/sharedStrings.xml"]
+	if !ok {
+		return nil, nil
+	}
+	rc, err := open(f, maxPartSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	t := tokens{d: xml.NewDecoder(rc)}
+	var out []string
+	for {
+		tok, err := t.next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "si" {
+			continue
+		}
+		if len(out) >= maxCells {
+			return nil, ErrTooLarge
+		}
+
+		var b strings.Builder
+		var path [2]string
+		for depth := 0; ; {
+			tok, err := t.next()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if _, ok := tok.(xml.EndElement); ok && depth == 0 {
+				break
+			}
+			switch e := tok.(type) {
+			case xml.StartElement:
+				if depth < len(path) {
+					path[depth] = e.Name.Local
+				}
+				depth++
+			case xml.EndElement:
+				depth--
+			case xml.CharData:
+				if isText(path[:], depth) {
+					b.Write(e)
+				}
+			}
+		}
+		out = append(out, b.String())
+	}
+}
+
+func decodeFile(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return errors.New("xlsx: missing " + name)
+	}
+	rc, err := open(f, maxMetaSize)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// open reads a zip entry, failing with ErrTooLarge past limit bytes
+// whatever the entry header claims.
+func open(f *zip.File, limit int64) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedPart{ReadCloser: rc, left: limit}, nil
+}
+
+type limitedPart struct {
+	io.ReadCloser
+	left int64
+}
+
+func (p *limitedPart) Read(b []byte) (int, error) {
+	if p.left < 0 {
+		return 0, ErrTooLarge
+	}
+	// one byte over the limit tells a part of exactly the limit from a
+	// larger one
+	if int64(len(b)) > p.left+1 {
+		b = b[:p.left+1]
+	}
+	n, err := p.ReadCloser.Read(b)
+	if p.left -= int64(n); p.left < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// columnIndex turns the letters of a cell reference like "AB12" into a
+// zero-based column index.
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, errors.New("xlsx: bad cell reference " + ref)
+	}
+	return col - 1, nil
+}
@@ -0,0 +1,173 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// book zips a minimal workbook around the given sheetData contents; sst is
+// the body of sharedStrings.xml, left out when empty.
+func book(t *testing.T, sheetData, sst string) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml":            workbookHead + `<sheet name="s" sheetId="1" r:id="rId1"/>` + workbookTail,
+		"xl/_rels/workbook.xml.rels": workbookRels,
+		"xl/worksheets/sheet1.xml":   sheetHead + sheetData + sheetTail,
+	}
+	if sst != "" {
+		parts["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sst + `</sst>`
+	}
+
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, body := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func read(data []byte, maxRows int) ([][]string, error) {
+	return ReadRows(bytes.NewReader(data), int64(len(data)), maxRows)
+}
+
+func TestReadRowsRoundTrip(t *testing.T) {
+	want := [][]string{
+		{"sku", "name", "qty"},
+		{"A-1", "", "5"},
+		{"B & <2>", "tab\there", "", "extra"},
+	}
+	var b bytes.Buffer
+	w, err := NewWriter(&b, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range want {
+		if err := w.WriteRow(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := read(b.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the writer leaves empty cells out, so trailing ones don't come back
+	want[1] = []string{"A-1", "", "5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadRowsCellTypes(t *testing.T) {
+	sst := `<si><t>plain</t></si>` +
+		`<si><r><t>rich </t></r><r><rPr><b/></rPr><t>text</t></r><rPh><t>ignored</t></rPh></si>`
+	data := book(t, `<row r="1">`+
+		`<c r="A1" t="s"><v>0</v></c>`+
+		`<c r="B1" t="s"><v> 1 </v></c>`+
+		`<c r="C1" t="inlineStr"><is><t>inline</t></is></c>`+
+		`<c r="D1" t="inlineStr"><is><r><t>in</t></r><r><t>runs</t></r></is></c>`+
+		`<c r="E1" t="b"><v>1</v></c>`+
+		`<c r="F1"><v>12.5</v></c>`+
+		`</row>`, sst)
+
+	got, err := read(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"plain", "rich text", "inline", "inruns", "TRUE", "12.5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if _, err := read(book(t, `<row><c r="A1" t="s"><v>2</v></c></row>`, sst), 0); err == nil {
+		t.Fatal("want an error for a shared string index out of range")
+	}
+}
+
+func TestReadRowsGaps(t *testing.T) {
+	data := book(t, `<row r="2"><c r="C2"><v>c</v></c><c><v>d</v></c></row>`+
+		`<row><c r="B3"><v>b</v></c></row>`+
+		`<row r="5"><c r="AA5"><v>aa</v></c></row>`, "")
+
+	got, err := read(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aa := make([]string, 27)
+	aa[26] = "aa"
+	want := [][]string{nil, {"", "", "c", "d"}, {"", "b"}, nil, aa}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadRowsBounds(t *testing.T) {
+	three := book(t, `<row r="1"/><row r="2"/><row r="3"><c r="A3"><v>x</v></c></row>`, "")
+	if _, err := read(three, 3); err != nil {
+		t.Fatalf("3 rows with maxRows 3: %v", err)
+	}
+	if _, err := read(three, 2); !errors.Is(err, ErrTooManyRows) {
+		t.Fatalf("3 rows with maxRows 2: got %v, want ErrTooManyRows", err)
+	}
+
+	if _, err := read(book(t, `<row r="1048577"/>`, ""), 0); err == nil {
+		t.Fatal("want an error for a row beyond the sheet limit")
+	}
+	if _, err := read(book(t, `<row><c r="XFD1"><v>x</v></c></row>`, ""), 0); err != nil {
+		t.Fatalf("last column: %v", err)
+	}
+	if _, err := read(book(t, `<row><c r="XFE1"><v>x</v></c></row>`, ""), 0); err == nil {
+		t.Fatal("want an error for a column beyond the sheet limit")
+	}
+
+	deep := strings.Repeat("<x>", maxDepth) + strings.Repeat("</x>", maxDepth)
+	if _, err := read(book(t, `<row><c r="A1">`+deep+`</c></row>`, ""), 0); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("deep nesting: got %v, want ErrTooLarge", err)
+	}
+}
+
+// Cells that repeat one position still count, not only the padding.
+func TestReadRowsCountsEveryCell(t *testing.T) {
+	defer func(n int) { maxCells = n }(maxCells)
+	maxCells = 100
+
+	same := book(t, `<row>`+strings.Repeat(`<c r="A1"/>`, maxCells+1)+`</row>`, "")
+	if _, err := read(same, 0); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("repeated cells: got %v, want ErrTooLarge", err)
+	}
+
+	padded := book(t, `<row><c r="CW1"/></row>`, "") // column 101
+	if _, err := read(padded, 0); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("padding: got %v, want ErrTooLarge", err)
+	}
+
+	other := book(t, `<row>`+strings.Repeat(`<x/>`, maxCells*elementsPerCell)+`</row>`, "")
+	if _, err := read(other, 0); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("other elements: got %v, want ErrTooLarge", err)
+	}
+
+	sst := strings.Repeat(`<si><t>x</t></si>`, maxCells+1)
+	if _, err := read(book(t, `<row/>`, sst), 0); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("shared strings: got %v, want ErrTooLarge", err)
+	}
+
+	if _, err := read(book(t, `<row>`+strings.Repeat(`<c r="A1"/>`, maxCells)+`</row>`, ""), 0); err != nil {
+		t.Fatalf("at the limit: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package xlsx writes single-sheet spreadsheets row by row straight into an
+// io.Writer, so large exports never have to be held in memory.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSheetFull is returned by WriteRow once only the last of MaxRows rows
+// is left; that one is kept for WriteLastRow.
+var ErrSheetFull = errors.New("xlsx: sheet row limit reached")
+
+type Writer struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+	buf   strings.Builder
+}
+
+// NewWriter starts a workbook with one sheet named sheet. Rows are appended
+// with WriteRow and the file is finished by Close.
+func NewWriter(w io.Writer, sheet string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	static := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbookHead + `<sheet name="` + escape(sheet) + `" sheetId="1" r:id="rId1"/>` + workbookTail},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+	}
+	for _, f := range static {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return nil, err
+		}
+	}
+
+	sw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sw, sheetHead); err != nil {
+		return nil, err
+	}
+	return &Writer{zw: zw, sheet: sw}, nil
+}
+
+// WriteRow appends one row of text cells.
+func (w *Writer) WriteRow(cells []string) error {
+	if w.row >= MaxRows-1 {
+		return ErrSheetFull
+	}
+	return w.writeRow(cells)
+}
+
+// WriteLastRow appends a row even when WriteRow reports ErrSheetFull, so a
+// cut off sheet can say so in its last row.
+func (w *Writer) WriteLastRow(cells []string) error {
+	if w.row >= MaxRows {
+		return ErrSheetFull
+	}
+	return w.writeRow(cells)
+}
+
+func (w *Writer) writeRow(cells []string) error {
+	w.row++
+	r := strconv.Itoa(w.row)
+
+	w.buf.Reset()
+	w.buf.WriteString(`<row r="` + r + `">`)
+	for i, c := range cells {
+		if c == "" {
+			continue
+		}
+		w.buf.WriteString(`<c r="` + column(i) + r + `" t="inlineStr"><is><t xml:space="preserve">`)
+		w.buf.WriteString(escape(c))
+		w.buf.WriteString(`</t></is></c>`)
+	}
+	w.buf.WriteString(`</row>`)
+
+	_, err := io.WriteString(w.sheet, w.buf.String())
+	return err
+}
+
+// Flush pushes compressed data written so far to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.zw.Flush()
+}
+
+func (w *Writer) Close() error {
+	if _, err := io.WriteString(w.sheet, sheetTail); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// column converts a zero-based index into a column name: 0 -> A, 26 -> AA.
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// escape makes s safe for XML text and drops characters XML 1.0 can't hold.
+func escape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || (r >= 0x20 && r != utf8.RuneError) {
+			return r
+		}
+		return -1
+	}, s)
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const workbookHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`
+
+const workbookTail = `</sheets></workbook>`
+
+const workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+const sheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const sheetTail = `</sheetData></worksheet>`
@@ -1,6 +1,14 @@
 let token = localStorage.getItem("token") || "";
+let refreshToken = localStorage.getItem("refreshToken") || "";
 let me = null;
 let selectedItemId = null;
+let nextCursor = null;
+let pageCursor = null;
+let pageNo = 1;
+let feed = null;
+let lastEventId = 0;
+let reloadTimer = null;
+const pageSize = 50;
 
 function qs(sel) { return document.querySelector(sel); }
 function out(obj) { qs("#out").textContent = typeof obj === "string" ? obj : JSON.stringify(obj, null, 2); }
@@ -21,35 +29,73 @@ function roleRank(role) {
 function canWrite() { return me && roleRank(me.role) >= 2; }
 function canDelete() { return me && me.role === "admin"; }
 
-async function api(path, opts = {}) {
-  const headers = opts.headers || {};
+function setTokens(data) {
+  token = data.token || "";
+  refreshToken = data.refresh_token || "";
+  localStorage.setItem("token", token);
+  localStorage.setItem("refreshToken", refreshToken);
+}
+
+function clearTokens() {
+  token = "";
+  refreshToken = "";
+  localStorage.removeItem("token");
+  localStorage.removeItem("refreshToken");
+}
+
+async function refreshTokens() {
+  if (!refreshToken) return false;
+  const res = await fetch("/api/auth/refresh", {
+    method: "POST",
+    headers: {"Content-Type":"application/json"},
+    body: JSON.stringify({refresh_token: refreshToken})
+  });
+  if (!res.ok) { clearTokens(); return false; }
+  setTokens(await res.json());
+  return true;
+}
+
+async function api(path, opts = {}, retry = true) {
+  const headers = { ...(opts.headers || {}) };
   if (token) headers["Authorization"] = "Bearer " + token;
-  return fetch(path, { ...opts, headers });
+  const res = await fetch(path, { ...opts, headers });
+  if (res.status === 401 && retry && await refreshTokens()) {
+    return api(path, opts, false);
+  }
+  return res;
 }
 
 async function login() {
   const username = qs("#username").value.trim();
-  const role = qs("#role").value;
+  const password = qs("#password").value;
 
   const res = await fetch("/api/auth/login", {
     method: "POST",
     headers: {"Content-Type":"application/json"},
-    body: JSON.stringify({username, role})
+    body: JSON.stringify({username, password})
   });
 
   const data = await res.json().catch(() => ({}));
   if (!res.ok) { out({status: res.status, ...data}); return; }
 
-  token = data.token || "";
-  localStorage.setItem("token", token);
+  qs("#password").value = "";
+  setTokens(data);
   await loadMe();
   await loadItems();
+  startFeed();
 }
 
-function logout() {
-  token = "";
+async function logout() {
+  if (token) {
+    await api("/api/auth/logout", {
+      method: "POST",
+      headers: {"Content-Type":"application/json"},
+      body: JSON.stringify({refresh_token: refreshToken})
+    }, false).catch(() => {});
+  }
+  stopFeed();
+  clearTokens();
   me = null;
-  localStorage.removeItem("token");
   setWhoami();
   setPermissionsUI();
   clearTables();
@@ -57,7 +103,7 @@ function logout() {
 }
 
 async function loadMe() {
-  if (!token) { me = null; return; }
+  if (!token && !refreshToken) { me = null; return; }
   const res = await api("/api/me");
   const data = await res.json().catch(() => ({}));
   if (!res.ok) {
@@ -96,17 +142,30 @@ function clearTables() {
   selectedItemId = null;
 }
 
-async function loadItems() {
+async function loadItems(cursor = null, keepPage = false) {
   if (!me) return;
+  const params = new URLSearchParams();
   const search = qs("#search").value.trim();
-  const url = search ? `/api/items?search=${encodeURIComponent(search)}` : "/api/items";
+  if (search) params.set("search", search);
+  params.set("sort", qs("#sort").value);
+  params.set("order", qs("#order").value);
+  params.set("limit", String(pageSize));
+  if (cursor) params.set("cursor", cursor);
 
-  const res = await api(url);
+  const res = await api(`/api/items?${params}`);
   const data = await res.json().catch(() => ({}));
   if (!res.ok) { out({status: res.status, ...data}); return; }
 
-  renderItems(data);
-  out({items: data.length});
+  if (!keepPage) pageNo = cursor ? pageNo + 1 : 1;
+  pageCursor = cursor;
+  nextCursor = data.next_cursor || null;
+  const total = Number(res.headers.get("X-Total-Count") || data.items.length);
+
+  renderItems(data.items);
+  qs("#btnNextPage").disabled = !nextCursor;
+  qs("#btnFirstPage").disabled = pageNo === 1;
+  qs("#pageInfo").textContent = `стр. ${pageNo} из ${Math.max(1, Math.ceil(total / pageSize))}, всего ${total}`;
+  out({items: data.items.length, total});
 }
 
 function renderItems(items) {
@@ -134,7 +193,7 @@ function renderItems(items) {
     btnDel.className = "secondary";
     btnDel.textContent = "Delete";
     btnDel.disabled = !canDelete();
-    btnDel.onclick = () => deleteItem(it.id);
+    btnDel.onclick = () => deleteItem(it);
 
     actions.appendChild(btnHist);
     actions.appendChild(btnEdit);
@@ -153,8 +212,11 @@ function renderItems(items) {
   }
 }
 
+function itemETag(it) { return `"${it.id}-v${it.version}"`; }
+
 function fillForm(it) {
   qs("#itemId").value = it.id;
+  qs("#itemVersion").value = it.version;
   qs("#sku").value = it.sku;
   qs("#name").value = it.name;
   qs("#qty").value = it.qty;
@@ -164,6 +226,7 @@ function fillForm(it) {
 
 function resetForm() {
   qs("#itemId").value = "";
+  qs("#itemVersion").value = "";
   qs("#sku").value = "";
   qs("#name").value = "";
   qs("#qty").value = 0;
@@ -184,13 +247,21 @@ async function saveItem(e) {
   };
 
   const isUpdate = id !== "";
+  const headers = {"Content-Type":"application/json"};
+  if (isUpdate) headers["If-Match"] = itemETag({id, version: qs("#itemVersion").value});
   const res = await api(isUpdate ? `/api/items/${id}` : "/api/items", {
     method: isUpdate ? "PUT" : "POST",
-    headers: {"Content-Type":"application/json"},
+    headers,
     body: JSON.stringify(payload)
   });
 
   const data = await res.json().catch(() => ({}));
+  if (res.status === 412) {
+    out({status: res.status, error: "item was changed by someone else, form reloaded", current: data.current});
+    fillForm(data.current);
+    await loadItems();
+    return;
+  }
   if (!res.ok) { out({status: res.status, ...data}); return; }
 
   out(data);
@@ -198,11 +269,12 @@ async function saveItem(e) {
   await loadItems();
 }
 
-async function deleteItem(id) {
+async function deleteItem(it) {
   if (!canDelete()) return;
+  const id = it.id;
   if (!confirm(`Delete item #${id}?`)) return;
 
-  const res = await api(`/api/items/${id}`, { method: "DELETE" });
+  const res = await api(`/api/items/${id}`, { method: "DELETE", headers: {"If-Match": itemETag(it)} });
   if (res.status === 204) {
     out({deleted: id});
     await loadItems();
@@ -296,6 +368,47 @@ async function exportCSV() {
   URL.revokeObjectURL(a.href);
 }
 
+// live updates: other people's changes reload the current page and history
+function startFeed() {
+  stopFeed();
+  if (!token) return;
+  const params = new URLSearchParams({access_token: token});
+  if (lastEventId) params.set("last_event_id", String(lastEventId));
+
+  feed = new EventSource(`/api/events?${params}`);
+  const types = ["item.created", "item.updated", "item.deleted", "item.reverted", "item.restored", "item.purged",
+    "item.lot_changed", "item.serial_changed"];
+  for (const type of types) {
+    feed.addEventListener(type, onItemEvent);
+  }
+  feed.addEventListener("reset", () => scheduleReload(true));
+  feed.onerror = async () => {
+    // the browser retries by itself unless the server refused (expired token)
+    if (!feed || feed.readyState !== EventSource.CLOSED) return;
+    feed = null;
+    if (await refreshTokens()) setTimeout(startFeed, 1000);
+  };
+}
+
+function stopFeed() {
+  if (feed) feed.close();
+  feed = null;
+}
+
+function onItemEvent(ev) {
+  lastEventId = Number(ev.lastEventId) || lastEventId;
+  const data = JSON.parse(ev.data);
+  scheduleReload(data.item_id === selectedItemId);
+}
+
+function scheduleReload(withHistory) {
+  clearTimeout(reloadTimer);
+  reloadTimer = setTimeout(async () => {
+    await loadItems(pageCursor, true);
+    if (withHistory) await loadHistory();
+  }, 300);
+}
+
 function escapeHtml(s) {
   return String(s)
     .replaceAll("&","&amp;")
@@ -307,7 +420,11 @@ function escapeHtml(s) {
 
 qs("#btnLogin").addEventListener("click", login);
 qs("#btnLogout").addEventListener("click", logout);
-qs("#btnRefresh").addEventListener("click", loadItems);
+qs("#btnRefresh").addEventListener("click", () => loadItems());
+qs("#btnFirstPage").addEventListener("click", () => loadItems());
+qs("#btnNextPage").addEventListener("click", () => loadItems(nextCursor));
+qs("#sort").addEventListener("change", () => loadItems());
+qs("#order").addEventListener("change", () => loadItems());
 qs("#itemForm").addEventListener("submit", saveItem);
 qs("#btnReset").addEventListener("click", resetForm);
 qs("#btnLoadHistory").addEventListener("click", loadHistory);
@@ -316,6 +433,9 @@ qs("#btnExportCsv").addEventListener("click", exportCSV);
 // on load
 (async function init() {
   await loadMe();
-  if (me) await loadItems();
+  if (me) {
+    await loadItems();
+    startFeed();
+  }
   setPermissionsUI();
 })();